@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/kelseyhightower/envconfig"
 	"github.com/rs/zerolog/log"
@@ -20,6 +21,7 @@ import (
 	"github.com/joho/godotenv"
 
 	"message-service-kata/pkg/di"
+	"message-service-kata/pkg/domain/entities"
 	"message-service-kata/pkg/timezone"
 	"message-service-kata/pkg/validator"
 
@@ -27,8 +29,17 @@ import (
 )
 
 var (
-	topicFlag   = flag.String("topic", "", fmt.Sprintf("Kafka topic to bind. available topic: \n\t - %s", strings.Join(app.Topics, ",\n\t - ")))
+	topicFlag   = flag.String("topic", "", "Kafka topic to bind or whose dead-letter queue to replay, default every registered topic. an unknown topic is rejected with the registered topics")
 	serviceFlag = flag.String("service", "", fmt.Sprintf("service to run. available services: \n\t - %s", strings.Join(infra.AvailableServices, ",\n\t - ")))
+
+	// dlq-replay flags, the dead-letter queue of -topic is replayed
+	dlqErrorClassFlag = flag.String("dlq-error-class", "", "dlq-replay: only replay messages with this x-error-class: retryable, permanent or transient_infra")
+	dlqKeyFlag        = flag.String("dlq-key", "", "dlq-replay: only replay messages with this key")
+	dlqFromFlag       = flag.String("dlq-from", "", "dlq-replay: only replay messages failed at or after this RFC 3339 time")
+	dlqToFlag         = flag.String("dlq-to", "", "dlq-replay: only replay messages failed before this RFC 3339 time")
+	dlqDryRunFlag     = flag.Bool("dlq-dry-run", false, "dlq-replay: log matching messages without republishing or committing them")
+	dlqRateFlag       = flag.Float64("dlq-rate", 10, "dlq-replay: maximum republished messages per second, 0 is unlimited")
+	dlqGroupFlag      = flag.String("dlq-group", "", "dlq-replay: consumer group keeping the replay progress, each partition stops at its first message filtered out. default <KAFKA_GROUP_ID>-dlq-replay")
 )
 
 func main() {
@@ -42,19 +53,64 @@ func main() {
 		os.Exit(1)
 	}
 
-	if *serviceFlag == infra.ServiceConsumerKafka && *topicFlag != "" {
-		if !slices.Contains(app.Topics, *topicFlag) {
-			fmt.Printf("unknown topic: %s\n", *topicFlag)
-			fmt.Print("\n\n")
-			flag.Usage()
+	if *serviceFlag == infra.ServiceDLQReplay && *topicFlag == "" {
+		fmt.Print("topic is required to replay its dead-letter queue\n")
+		fmt.Print("\n\n")
+		flag.Usage()
 
-			os.Exit(1)
-		}
+		os.Exit(1)
 	}
 
 	serve(*serviceFlag, *topicFlag)
 }
 
+// validateTopic check the topic to bind or replay has a registered handler, empty topic binds every registered topic
+func validateTopic(registry kafkaCtrl.HandlerRegistry, topic string) error {
+	if topic == "" {
+		return nil
+	}
+
+	if _, ok := registry.Handler(topic); !ok {
+		return fmt.Errorf("unknown topic: %s. registered topics: %s", topic, strings.Join(registry.Topics(), ", "))
+	}
+
+	return nil
+}
+
+// dlqReplayRequest build dead-letter replay request of the topic from the dlq-replay flags
+func dlqReplayRequest(topic string) (req entities.ReplayDeadLetterRequest, err error) {
+	req = entities.ReplayDeadLetterRequest{
+		Topic:   topic,
+		GroupID: *dlqGroupFlag,
+		DryRun:  *dlqDryRunFlag,
+		Rate:    *dlqRateFlag,
+		DeadLetterFilter: entities.DeadLetterFilter{
+			ErrorClass: *dlqErrorClassFlag,
+			Key:        *dlqKeyFlag,
+		},
+	}
+
+	if req.Rate < 0 {
+		return req, fmt.Errorf("dlq-rate must not be negative, got %v", req.Rate)
+	}
+
+	if *dlqFromFlag != "" {
+		req.FailedFrom, err = time.Parse(time.RFC3339, *dlqFromFlag)
+		if err != nil {
+			return req, fmt.Errorf("dlq-from: %w", err)
+		}
+	}
+
+	if *dlqToFlag != "" {
+		req.FailedTo, err = time.Parse(time.RFC3339, *dlqToFlag)
+		if err != nil {
+			return req, fmt.Errorf("dlq-to: %w", err)
+		}
+	}
+
+	return req, nil
+}
+
 func serve(serviceNameFlag, topicNameFlag string) {
 	err := godotenv.Load()
 	if err != nil {
@@ -82,6 +138,10 @@ func serve(serviceNameFlag, topicNameFlag string) {
 		err = LoadApplicationRestPackage()
 	case infra.ServiceConsumerKafka:
 		err = LoadApplicationKafkaPackage()
+	case infra.ServiceDLQReplay:
+		err = LoadApplicationDLQPackage()
+	case infra.ServiceOutboxRelay:
+		err = LoadApplicationOutboxPackage()
 	}
 	if err != nil {
 		log.Fatal().Msg(err.Error())
@@ -102,11 +162,34 @@ func serve(serviceNameFlag, topicNameFlag string) {
 		log.Fatal().Msg(err.Error())
 	}
 
+	// Topic handlers are validated at startup, the topic to bind or replay must be one of them
+	if serviceNameFlag == infra.ServiceConsumerKafka || serviceNameFlag == infra.ServiceDLQReplay {
+		err = di.Invoke(func(registry kafkaCtrl.HandlerRegistry) error {
+			return validateTopic(registry, topicNameFlag)
+		})
+		if err != nil {
+			log.Fatal().Msg(err.Error())
+		}
+	}
+
 	switch serviceNameFlag {
 	case infra.ServiceRestAPI:
 		app.StartRestServer()
 	case infra.ServiceConsumerKafka:
 		app.StartConsumerServer(topicNameFlag)
+	case infra.ServiceDLQReplay:
+		req, err := dlqReplayRequest(topicNameFlag)
+		if err != nil {
+			log.Fatal().Msg(err.Error())
+		}
+
+		if err = app.StartDLQReplay(req); err != nil {
+			log.Fatal().Msg(err.Error())
+		}
+	case infra.ServiceOutboxRelay:
+		if err = app.StartOutboxRelay(); err != nil {
+			log.Fatal().Msg(err.Error())
+		}
 	}
 }
 
@@ -150,6 +233,27 @@ func LoadApplicationRestPackage() error {
 		return fmt.Errorf("NewProducer: %s", err.Error())
 	}
 
+	err = di.Provide(infra.NewReplyConsumer)
+	if err != nil {
+		return fmt.Errorf("NewReplyConsumer: %s", err.Error())
+	}
+
+	err = di.Provide(infra.NewInvalidationConsumer)
+	if err != nil {
+		return fmt.Errorf("NewInvalidationConsumer: %s", err.Error())
+	}
+
+	err = di.Provide(infra.NewDeadLetterReader)
+	if err != nil {
+		return fmt.Errorf("NewDeadLetterReader: %s", err.Error())
+	}
+
+	// controller
+	err = di.Provide(kafkaCtrl.NewProcessor)
+	if err != nil {
+		return fmt.Errorf("NewKafkaController: %s", err.Error())
+	}
+
 	err = di.Invoke(validator.NewValidator)
 	if err != nil {
 		return fmt.Errorf("NewValidator: %s", err.Error())
@@ -177,12 +281,69 @@ func LoadApplicationKafkaPackage() error {
 		return fmt.Errorf("NewProducer: %s", err.Error())
 	}
 
+	err = di.Provide(infra.NewInvalidationConsumer)
+	if err != nil {
+		return fmt.Errorf("NewInvalidationConsumer: %s", err.Error())
+	}
+
+	return LoadTopicHandlers()
+}
+
+// LoadApplicationDLQPackage Load application package used by the dead-letter queue replay, the topic handlers are
+// loaded to validate the replayed topic
+func LoadApplicationDLQPackage() error {
+	err := di.Provide(infra.NewDatabases)
+	if err != nil {
+		return fmt.Errorf("NewDatabases: %s", err.Error())
+	}
+
+	err = di.Provide(infra.NewProducer)
+	if err != nil {
+		return fmt.Errorf("NewProducer: %s", err.Error())
+	}
+
+	return LoadTopicHandlers()
+}
+
+// LoadTopicHandlers Load kafka processor and the handler registry of the consumed topics
+func LoadTopicHandlers() error {
 	// controller
-	err = di.Provide(kafkaCtrl.NewProcessor)
+	err := di.Provide(kafkaCtrl.NewProcessor)
 	if err != nil {
 		return fmt.Errorf("NewKafkaController: %s", err.Error())
 	}
 
+	// topic handlers
+	err = di.Provide(kafkaCtrl.NewPublishMessageHandler)
+	if err != nil {
+		return fmt.Errorf("NewPublishMessageHandler: %s", err.Error())
+	}
+
+	err = di.Provide(kafkaCtrl.NewCheckoutHandler)
+	if err != nil {
+		return fmt.Errorf("NewCheckoutHandler: %s", err.Error())
+	}
+
+	err = di.Provide(kafkaCtrl.NewHandlerRegistry)
+	if err != nil {
+		return fmt.Errorf("NewHandlerRegistry: %s", err.Error())
+	}
+
+	return nil
+}
+
+// LoadApplicationOutboxPackage Load application package used by the outbox relay
+func LoadApplicationOutboxPackage() error {
+	err := di.Provide(infra.NewDatabases)
+	if err != nil {
+		return fmt.Errorf("NewDatabases: %s", err.Error())
+	}
+
+	err = di.Provide(infra.NewProducer)
+	if err != nil {
+		return fmt.Errorf("NewProducer: %s", err.Error())
+	}
+
 	return nil
 }
 
@@ -196,23 +357,93 @@ func LoadApplicationRepository() error {
 		return fmt.Errorf("NewKafkaRepository: %s", err.Error())
 	}
 
+	err = di.Provide(kafka.NewDeadLetterRepository)
+	if err != nil {
+		return fmt.Errorf("NewDeadLetterRepository: %s", err.Error())
+	}
+
 	// repo postgres
 	err = di.Provide(postgres.NewMessageRepository)
 	if err != nil {
 		return fmt.Errorf("NewMessageRepository: %s", err.Error())
 	}
 
+	err = di.Provide(postgres.NewBatchRepository)
+	if err != nil {
+		return fmt.Errorf("NewBatchRepository: %s", err.Error())
+	}
+
+	err = di.Provide(postgres.NewOutboxRepository)
+	if err != nil {
+		return fmt.Errorf("NewOutboxRepository: %s", err.Error())
+	}
+
+	err = di.Provide(postgres.NewConversationRepository)
+	if err != nil {
+		return fmt.Errorf("NewConversationRepository: %s", err.Error())
+	}
+
+	err = di.Provide(postgres.NewResponseRepository)
+	if err != nil {
+		return fmt.Errorf("NewResponseRepository: %s", err.Error())
+	}
+
 	return nil
 }
 
 // LoadApplicationService Load service or usecase of the application using uber dig
 func LoadApplicationService() error {
 	// service
-	err := di.Provide(service.NewMessageSvc)
+	err := di.Provide(service.NewResponseCatalog)
+	if err != nil {
+		return fmt.Errorf("NewResponseCatalog: %s", err.Error())
+	}
+
+	err = di.Provide(service.NewCatalogResponseEngine)
+	if err != nil {
+		return fmt.Errorf("NewCatalogResponseEngine: %s", err.Error())
+	}
+
+	err = di.Provide(service.NewConfiguredDialogEngine)
+	if err != nil {
+		return fmt.Errorf("NewConfiguredDialogEngine: %s", err.Error())
+	}
+
+	err = di.Provide(service.NewMessageSvc)
 	if err != nil {
 		return fmt.Errorf("NewMessageSvc: %s", err.Error())
 	}
 
+	err = di.Provide(service.NewOutboxSvc)
+	if err != nil {
+		return fmt.Errorf("NewOutboxSvc: %s", err.Error())
+	}
+
+	err = di.Provide(service.NewResponseSvc)
+	if err != nil {
+		return fmt.Errorf("NewResponseSvc: %s", err.Error())
+	}
+
+	err = di.Provide(service.NewReplyRegistry)
+	if err != nil {
+		return fmt.Errorf("NewReplyRegistry: %s", err.Error())
+	}
+
+	err = di.Provide(service.NewChatSvc)
+	if err != nil {
+		return fmt.Errorf("NewChatSvc: %s", err.Error())
+	}
+
+	err = di.Provide(service.NewStreamSvc)
+	if err != nil {
+		return fmt.Errorf("NewStreamSvc: %s", err.Error())
+	}
+
+	err = di.Provide(service.NewDeadLetterSvc)
+	if err != nil {
+		return fmt.Errorf("NewDeadLetterSvc: %s", err.Error())
+	}
+
 	return nil
 }
 
@@ -224,5 +455,20 @@ func LoadApplicationController() error {
 		return fmt.Errorf("NewMessageCtrl: %s", err.Error())
 	}
 
+	err = di.Provide(controller.NewResponseCtrl)
+	if err != nil {
+		return fmt.Errorf("NewResponseCtrl: %s", err.Error())
+	}
+
+	err = di.Provide(controller.NewGatewayCtrl)
+	if err != nil {
+		return fmt.Errorf("NewGatewayCtrl: %s", err.Error())
+	}
+
+	err = di.Provide(controller.NewDeadLetterCtrl)
+	if err != nil {
+		return fmt.Errorf("NewDeadLetterCtrl: %s", err.Error())
+	}
+
 	return nil
 }
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	kafkaCtrl "message-service-kata/internal/app/controller/kafka"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+func TestValidateTopic(t *testing.T) {
+	handlers := make([]kafkaCtrl.TopicHandler, 0, 2)
+	for _, topic := range []string{"message.publish", "portfolio.checkout_success"} {
+		handlers = append(handlers, kafkaCtrl.TopicHandler{
+			Topic:       topic,
+			Payload:     map[string]interface{}{},
+			Concurrency: 1,
+			Handle: func(ctx context.Context, message *kafka.Message, payload interface{}) error {
+				return nil
+			},
+		})
+	}
+
+	registry, err := kafkaCtrl.NewHandlerRegistry(kafkaCtrl.HandlerRegistryImpl{Handlers: handlers})
+	if err != nil {
+		t.Fatalf("NewHandlerRegistry() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		topic   string
+		wantErr string
+	}{
+		{name: "every topic", topic: ""},
+		{name: "registered topic", topic: "message.publish"},
+		{
+			name:    "unknown topic lists the registered topics",
+			topic:   "message.unknown",
+			wantErr: "unknown topic: message.unknown. registered topics: message.publish, portfolio.checkout_success",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTopic(registry, tt.topic)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validateTopic() error = %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("validateTopic() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
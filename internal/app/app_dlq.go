@@ -0,0 +1,44 @@
+package app
+
+import (
+	"context"
+	"os/signal"
+
+	"message-service-kata/internal/app/service"
+	"message-service-kata/pkg/di"
+	"message-service-kata/pkg/domain/entities"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/rs/zerolog/log"
+)
+
+// dlqFlushTimeoutMs is the longest wait for messages left in the producer queue before exiting
+const dlqFlushTimeoutMs = 10000
+
+// StartDLQReplay - function to replay the dead-letter queue of a topic once, it stops on exit signal
+func StartDLQReplay(req entities.ReplayDeadLetterRequest) (err error) {
+	ctx, stop := signal.NotifyContext(context.Background(), exitSigs...)
+	defer stop()
+
+	invokeErr := di.Invoke(func(deadLetterSvc service.DeadLetterSvc, producer *kafka.Producer) {
+		defer producer.Close()
+		defer producer.Flush(dlqFlushTimeoutMs)
+
+		log.Info().
+			Any("topic", req.Topic).
+			Any("dry_run", req.DryRun).
+			Any("rate", req.Rate).
+			Any("filter", req.DeadLetterFilter).
+			Msg("replaying dead-letter queue")
+
+		var result entities.DeadLetterReplayResult
+		result, err = deadLetterSvc.ReplayDeadLetters(ctx, &req)
+
+		log.Info().Any("result", result).Msg("dead-letter replay finished")
+	})
+	if invokeErr != nil {
+		return invokeErr
+	}
+
+	return err
+}
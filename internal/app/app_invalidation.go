@@ -0,0 +1,85 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	kafkaCtrl "message-service-kata/internal/app/controller/kafka"
+	"message-service-kata/internal/app/infra"
+	"message-service-kata/internal/app/service"
+	"message-service-kata/pkg/domain/entities"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/rs/zerolog/log"
+	"go.uber.org/dig"
+)
+
+const (
+	// invalidationPollTimeout is the longest wait for an invalidation before checking shutdown again
+	invalidationPollTimeout = time.Second
+	// invalidationRefreshTimeout is the timeout of refreshing chatbot responses once partitions are assigned
+	invalidationRefreshTimeout = 10 * time.Second
+)
+
+type (
+	// InvalidationConsumerParams is a chatbot response invalidation consumer dependencies
+	InvalidationConsumerParams struct {
+		dig.In
+		Consumer  *infra.InvalidationConsumer
+		KafkaCtrl kafkaCtrl.Processor
+		Catalog   service.ResponseCatalog
+	}
+)
+
+// startInvalidationConsumer consume chatbot response invalidations until shutdown, so every instance refreshes
+// its own cached chatbot responses. Offsets are not committed because each instance starts from the latest offset.
+func startInvalidationConsumer(args InvalidationConsumerParams, shutdownCh <-chan struct{}) {
+	defer func() {
+		if err := args.Consumer.Close(); err != nil {
+			log.Error().Msgf("invalidation consumer.Close: %s", err.Error())
+		}
+	}()
+
+	// Responses changed between startup and the partition assignment are picked up by a refresh once assigned
+	rebalance := func(c *kafka.Consumer, event kafka.Event) error {
+		err := rebalanceCallback(c, event)
+
+		if ev, ok := event.(kafka.AssignedPartitions); ok && len(ev.Partitions) > 0 {
+			ctx, cancel := context.WithTimeout(context.Background(), invalidationRefreshTimeout)
+			defer cancel()
+
+			if errs := args.Catalog.Refresh(ctx); errs != nil {
+				log.Error().Msgf("[invalidationConsumer] error while Refresh, keep version %s : %v", args.Catalog.Version(), errs)
+			}
+		}
+
+		return err
+	}
+
+	err := args.Consumer.Subscribe(string(entities.TopicResponseInvalidate), rebalance)
+	if err != nil {
+		log.Error().Msgf("Subscribe invalidation topic: %s", err.Error())
+		return
+	}
+
+	for {
+		select {
+		case <-shutdownCh:
+			log.Info().Msg("shutdown invalidation consumer")
+			return
+		default:
+			msg, err := args.Consumer.ReadMessage(invalidationPollTimeout)
+			if err != nil {
+				var kafkaErr kafka.Error
+				if !errors.As(err, &kafkaErr) || kafkaErr.Code() != kafka.ErrTimedOut {
+					log.Error().Msgf("ReadMessage invalidation: %s", err.Error())
+				}
+				continue
+			}
+
+			// A missed invalidation is caught up by the periodic refresh of the response catalog
+			_ = args.KafkaCtrl.ProcessResponseInvalidation(context.Background(), msg)
+		}
+	}
+}
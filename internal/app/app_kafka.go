@@ -2,12 +2,14 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	kafkaCtrl "message-service-kata/internal/app/controller/kafka"
 	"message-service-kata/internal/app/infra"
 	"os"
+	"time"
 
-	"message-service-kata/pkg/domain/entities"
+	"message-service-kata/pkg/cerror"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 	"github.com/rs/zerolog/log"
@@ -18,17 +20,15 @@ type (
 	// ConsumerHandlerParams is a consumer handler dependencies
 	ConsumerHandlerParams struct {
 		dig.In
-		Consumer  *kafka.Consumer
-		Producer  *kafka.Producer
-		KafkaCfg  *infra.KafkaCfg
-		KafkaCtrl kafkaCtrl.Processor
+		Consumer *kafka.Consumer
+		Producer *kafka.Producer
+		KafkaCfg *infra.KafkaCfg
+		Handlers kafkaCtrl.HandlerRegistry
 	}
 )
 
-// Topics is a variable for list of kafka topic.
-var Topics = []string{
-	string(entities.TopicPublishMessage),
-}
+// consumerPollTimeout is the longest wait for a message before checking shutdown again
+const consumerPollTimeout = time.Second
 
 func startConsumer(
 	args ConsumerHandlerParams,
@@ -36,100 +36,81 @@ func startConsumer(
 	errCh chan<- error,
 	topic string,
 ) {
-	topics := Topics
+	topics := args.Handlers.Topics()
 	if topic != "" {
+		if _, ok := args.Handlers.Handler(topic); !ok {
+			err := fmt.Errorf("no handler registered for topic %s", topic)
+			log.Error().Msgf("startConsumer: %s", err.Error())
+			errCh <- err // send error to error channel
+			return
+		}
+
 		topics = []string{topic}
 	}
 
-	err := args.Consumer.SubscribeTopics(topics, rebalanceCallback)
+	retry := newRetryPolicy(args, topics)
+	pipeline := newConsumerPipeline(args, retry)
+
+	// Retry topics are consumed by the same consumer as their original topic
+	subscribed := make([]string, 0, len(topics)+len(retry.tiers))
+	subscribed = append(subscribed, topics...)
+	subscribed = append(subscribed, retry.retryTopics()...)
+
+	err := args.Consumer.SubscribeTopics(subscribed, pipeline.rebalance)
 	if err != nil {
 		log.Error().Msgf("SubscribeTopics: %s", err.Error())
 		errCh <- err // send error to error channel
 		return
 	}
+	defer pipeline.drain()
 
-	retryCount := make(map[kafka.TopicPartition]int)
 	for {
 		select {
 		case <-shutdownCh:
 			log.Info().Msg("shutdown consumer")
 			return
 		default:
-			msg, err := args.Consumer.ReadMessage(-1)
-			if err != nil {
-				log.Error().Msgf("ReadMessage: %s", err.Error())
-				errCh <- err
-				return
-			}
-
-			isRetryProcessMessage := true
-			for isRetryProcessMessage {
-				err = handleMessage(msg, args)
-				if err != nil {
-					log.Error().Any("topic", msg.TopicPartition).Any("value", string(msg.Value)).Any("error", err).Msg("error process kafka message")
-
-					// If the error is retryable and retry count is less than maxRetryCount, don't commit the offset.
-					if retryCount[msg.TopicPartition] < args.KafkaCfg.MaxConsumerRetries {
-						log.Warn().
-							Any("topic", msg.TopicPartition).
-							Any("value", string(msg.Value)).
-							Any("retry count", retryCount[msg.TopicPartition]).
-							Msg("Kafka retry")
-
-						retryCount[msg.TopicPartition]++
-						continue
-					}
+			pipeline.resumeDue()
 
-					// If the error is not retryable or retry count has reached maxRetryCount,
-					// forward the message to the dead-letter queue and commit the offset.
-					// Build the DLQ topic name
-					dlqTopic := fmt.Sprintf("%s-dead-letter-queue", *msg.TopicPartition.Topic)
-					err = args.Producer.Produce(&kafka.Message{
-						TopicPartition: kafka.TopicPartition{Topic: &dlqTopic, Partition: kafka.PartitionAny},
-						Value:          msg.Value,
-						Key:            msg.Key,
-					}, nil)
-					if err != nil {
-						log.Error().Any("topic", dlqTopic).Any("value", string(msg.Value)).Any("error", err).Msg("error process produce dlq message")
+			msg, err := args.Consumer.ReadMessage(consumerPollTimeout)
+			if err != nil {
+				// Timeout and non fatal errors such as a retry topic which does not exist yet are recovered by the client
+				var kafkaErr kafka.Error
+				if errors.As(err, &kafkaErr) && !kafkaErr.IsFatal() {
+					if kafkaErr.Code() != kafka.ErrTimedOut {
+						log.Warn().Msgf("ReadMessage: %s", err.Error())
 					}
+					continue
 				}
 
-				// If the message has been consumed or forwarded to the dead-letter queue, commit the offset.
-				_, err = args.Consumer.CommitMessage(msg)
-				if err != nil {
-					log.Error().Msgf("Failed to commit message: %s", err.Error())
+				log.Error().Msgf("ReadMessage: %s", err.Error())
+				select {
+				case errCh <- err:
+				case <-shutdownCh:
 				}
-
-				// Reset retry count for the message
-				retryCount[msg.TopicPartition] = 0
-
-				// delete key
-				delete(retryCount, msg.TopicPartition)
-
-				// stop retry process
-				isRetryProcessMessage = false
+				return
 			}
+
+			pipeline.submit(msg)
 		}
 	}
 }
 
+// handleMessage process message of the topic with its registered handler, message of a retry topic is handled
+// as its original topic
 func handleMessage(
+	topic string,
 	msg *kafka.Message,
 	args ConsumerHandlerParams,
 ) (err error) {
-	var topic string
-
 	ctx := context.Background()
 
-	if msg.TopicPartition.Topic != nil {
-		topic = *msg.TopicPartition.Topic
-	}
-
-	switch topic {
-	case string(entities.TopicPublishMessage):
-		err = args.KafkaCtrl.ProcessMessage(ctx, msg)
-	default:
-		err = nil
+	handler, ok := args.Handlers.Handler(topic)
+	if ok {
+		err = handler.Process(ctx, msg)
+	} else {
+		// Subscribed topics all have a handler, no attempt can succeed without one
+		err = cerror.Permanent(fmt.Errorf("no handler registered for topic %s", topic))
 	}
 
 	if err != nil {
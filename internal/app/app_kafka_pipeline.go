@@ -0,0 +1,508 @@
+package app
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/rs/zerolog/log"
+)
+
+type (
+	// consumerPipeline process consumed messages on workers of the assigned partitions, so partitions are processed
+	// in parallel. Workers are started when a partition is assigned and drained when it is revoked. It is only used
+	// from the reading goroutine, rebalance callback is called by the consumer poll on that goroutine too.
+	consumerPipeline struct {
+		args       ConsumerHandlerParams
+		retry      *retryPolicy
+		partitions map[partitionKey]*partitionWorkers
+		// paused hold the partitions waiting for their next message to be due or for room in its worker
+		paused map[partitionKey]pausedPartition
+	}
+
+	// pausedPartition is a partition paused and rewound to its next message until that message is due and, when full
+	// is set, until the worker the message was handed to has room for it
+	pausedPartition struct {
+		tp   kafka.TopicPartition
+		due  time.Time
+		full <-chan *kafka.Message
+	}
+
+	// partitionKey identify topic partition regardless of offset
+	partitionKey struct {
+		topic     string
+		partition int32
+	}
+
+	// partitionWorkers process messages of a partition on the concurrency workers of its topic handler. Messages with the same
+	// key go to the same worker so they are processed in order, keyless messages are spread over the workers.
+	// The partition is paused once a worker has KafkaCfg.ConsumerBuffer messages waiting.
+	partitionWorkers struct {
+		args    ConsumerHandlerParams
+		retry   *retryPolicy
+		stop    chan struct{}
+		lanes   []chan *kafka.Message
+		next    int
+		offsets *partitionOffsets
+		wg      sync.WaitGroup
+	}
+
+	// workerLane process the messages of a worker. A message retried in place waits for its backoff on a timer instead
+	// of the worker, so the worker keeps processing the other messages. Later messages with the same key are held back
+	// until the retried message is done, so they stay in order. A keyless message is held back behind any retried
+	// message of the lane and holds back every later message, so keyless messages keep the order of the partition.
+	// A message failed on an unavailable dependency holds back every later message too, until the dependency recovers.
+	workerLane struct {
+		workers *partitionWorkers
+		due     chan laneRetry
+		// retrying hold the pending retry of every key, keyless message under the empty key, and holding count
+		// the ones holding back the whole lane
+		retrying map[string]pendingRetry
+		holding  int
+		// held hold the messages held back behind a retried message, oldest first, and heldKeys count them by key
+		held     []*kafka.Message
+		heldKeys map[string]int
+	}
+
+	// laneRetry is a message due for its next attempt, retryCount are its in place retries and holdCount its attempts
+	// failed on an unavailable dependency
+	laneRetry struct {
+		msg        *kafka.Message
+		retryCount int
+		holdCount  int
+	}
+
+	// pendingRetry is the backoff timer of a retried message, hold is set when it holds back the whole lane
+	pendingRetry struct {
+		timer *time.Timer
+		hold  bool
+	}
+
+	// partitionOffsets hold in flight offsets of a partition in consumed order, an offset is committed
+	// only when every message before it in the partition is done
+	partitionOffsets struct {
+		mu       sync.Mutex
+		inflight []kafka.Offset
+		finished map[kafka.Offset]struct{}
+	}
+)
+
+// newConsumerPipeline initiating pipeline without partition, workers are started by rebalance
+func newConsumerPipeline(args ConsumerHandlerParams, retry *retryPolicy) *consumerPipeline {
+	return &consumerPipeline{
+		args:       args,
+		retry:      retry,
+		partitions: make(map[partitionKey]*partitionWorkers),
+		paused:     make(map[partitionKey]pausedPartition),
+	}
+}
+
+// rebalance start workers of assigned partitions and drain workers of revoked partitions, offsets of the
+// revoked partitions are committed before the consumer gives them up
+func (p *consumerPipeline) rebalance(c *kafka.Consumer, event kafka.Event) error {
+	err := rebalanceCallback(c, event)
+
+	switch ev := event.(type) {
+	case kafka.AssignedPartitions:
+		for _, tp := range ev.Partitions {
+			p.start(tp)
+		}
+	case kafka.RevokedPartitions:
+		for _, tp := range ev.Partitions {
+			delete(p.paused, newPartitionKey(tp))
+			p.stop(newPartitionKey(tp))
+		}
+	}
+
+	return err
+}
+
+// submit hand message over to the workers of its partition. Message of a retry topic which is not due yet pauses its
+// partition instead, so no worker waits for the tier delay. Message whose worker is full pauses its partition until
+// the worker has room, so the reader keeps polling while a worker is stuck.
+func (p *consumerPipeline) submit(msg *kafka.Message) {
+	key := newPartitionKey(msg.TopicPartition)
+
+	// Message fetched before its partition was paused is consumed again once the partition is resumed
+	if _, ok := p.paused[key]; ok {
+		return
+	}
+
+	if due, ok := p.retry.due(msg); ok && time.Now().Before(due) && p.pause(msg, pausedPartition{due: due}) {
+		return
+	}
+
+	workers, ok := p.partitions[key]
+	if !ok {
+		workers = p.start(msg.TopicPartition)
+	}
+
+	full := workers.submit(msg, false)
+	if full == nil {
+		return
+	}
+
+	log.Warn().Msgf("[consumerPipeline] worker of partition %v is full, pause partition", msg.TopicPartition)
+	if !p.pause(msg, pausedPartition{full: full}) {
+		workers.submit(msg, true)
+	}
+}
+
+// pause stop fetching the partition of the message and rewind it to the message until it can be resumed. It returns
+// false when the partition can not be paused, the message is then handed over right away rather than lost.
+func (p *consumerPipeline) pause(msg *kafka.Message, paused pausedPartition) bool {
+	tp := msg.TopicPartition
+	partitions := []kafka.TopicPartition{tp}
+
+	err := p.args.Consumer.Pause(partitions)
+	if err != nil {
+		log.Error().Msgf("Failed to pause partition %v: %s", tp, err.Error())
+		return false
+	}
+
+	err = p.args.Consumer.Seek(tp, 0)
+	if err != nil {
+		log.Error().Msgf("Failed to seek partition %v: %s", tp, err.Error())
+
+		if err = p.args.Consumer.Resume(partitions); err != nil {
+			log.Error().Msgf("Failed to resume partition %v: %s", tp, err.Error())
+		}
+		return false
+	}
+
+	paused.tp = tp
+	p.paused[newPartitionKey(tp)] = paused
+
+	return true
+}
+
+// resumeDue resume the paused partitions whose next message is due and whose worker has room, a partition which fails
+// to resume is tried again on the next call
+func (p *consumerPipeline) resumeDue() {
+	now := time.Now()
+
+	for key, paused := range p.paused {
+		if now.Before(paused.due) || (paused.full != nil && len(paused.full) == cap(paused.full)) {
+			continue
+		}
+
+		err := p.args.Consumer.Resume([]kafka.TopicPartition{paused.tp})
+		if err != nil {
+			log.Error().Msgf("Failed to resume partition %v: %s", paused.tp, err.Error())
+			continue
+		}
+
+		delete(p.paused, key)
+	}
+}
+
+// drain stop the workers of every partition and wait for their in flight messages to be processed and committed,
+// messages waiting for a retry are left uncommitted and consumed again
+func (p *consumerPipeline) drain() {
+	for key := range p.partitions {
+		p.stop(key)
+	}
+}
+
+// start workers of the partition unless they are already running
+func (p *consumerPipeline) start(tp kafka.TopicPartition) *partitionWorkers {
+	key := newPartitionKey(tp)
+	if workers, ok := p.partitions[key]; ok {
+		return workers
+	}
+
+	// Retry topics share the concurrency of their original topic
+	concurrency := 1
+	if handler, ok := p.args.Handlers.Handler(p.retry.originalTopic(key.topic)); ok {
+		concurrency = handler.Concurrency
+	}
+
+	workers := newPartitionWorkers(p.args, p.retry, concurrency)
+	p.partitions[key] = workers
+
+	return workers
+}
+
+// stop workers of the partition and wait for them to finish
+func (p *consumerPipeline) stop(key partitionKey) {
+	workers, ok := p.partitions[key]
+	if !ok {
+		return
+	}
+	delete(p.partitions, key)
+
+	workers.drain()
+
+	log.Info().Msgf("[consumerPipeline] drained partition %s[%d]", key.topic, key.partition)
+}
+
+// newPartitionWorkers start concurrency workers of a partition
+func newPartitionWorkers(args ConsumerHandlerParams, retry *retryPolicy, concurrency int) *partitionWorkers {
+	w := &partitionWorkers{
+		args:    args,
+		retry:   retry,
+		stop:    make(chan struct{}),
+		lanes:   make([]chan *kafka.Message, concurrency),
+		offsets: &partitionOffsets{finished: make(map[kafka.Offset]struct{})},
+	}
+
+	for i := range w.lanes {
+		w.lanes[i] = make(chan *kafka.Message, args.KafkaCfg.ConsumerBuffer)
+
+		w.wg.Add(1)
+		go func(lane <-chan *kafka.Message) {
+			defer w.wg.Done()
+
+			newWorkerLane(w).run(lane)
+		}(w.lanes[i])
+	}
+
+	return w
+}
+
+// submit hand message over to its worker, offsets are tracked in the order messages are submitted. Unless wait is set,
+// a message whose worker is full is not handed over and the full worker is returned.
+func (w *partitionWorkers) submit(msg *kafka.Message, wait bool) (full <-chan *kafka.Message) {
+	w.offsets.add(msg.TopicPartition.Offset)
+
+	var lane int
+	if len(msg.Key) > 0 && len(w.lanes) > 1 {
+		hash := fnv.New32a()
+		_, _ = hash.Write(msg.Key)
+		lane = int(hash.Sum32() % uint32(len(w.lanes)))
+	} else {
+		lane = w.next
+		w.next = (w.next + 1) % len(w.lanes)
+	}
+
+	if wait {
+		w.lanes[lane] <- msg
+		return nil
+	}
+
+	select {
+	case w.lanes[lane] <- msg:
+		return nil
+	default:
+		w.offsets.cancel(msg.TopicPartition.Offset)
+		return w.lanes[lane]
+	}
+}
+
+// drain stop accepting messages and wait for the in flight ones to be processed and committed. Messages waiting for
+// a retry and messages held back behind them are cancelled, they are left uncommitted and consumed again by the next
+// owner of the partition.
+func (w *partitionWorkers) drain() {
+	close(w.stop)
+	for _, lane := range w.lanes {
+		close(lane)
+	}
+
+	w.wg.Wait()
+}
+
+// commit mark message done and commit the offset after the last contiguous done message of the partition
+func (w *partitionWorkers) commit(msg *kafka.Message) {
+	w.offsets.done(msg.TopicPartition.Offset, func(offset kafka.Offset) {
+		tp := msg.TopicPartition
+		tp.Offset = offset
+
+		_, err := w.args.Consumer.CommitOffsets([]kafka.TopicPartition{tp})
+		if err != nil {
+			log.Error().Msgf("Failed to commit offset %v: %s", tp, err.Error())
+		}
+	})
+}
+
+// newWorkerLane initiating lane of the partition workers without retried message
+func newWorkerLane(workers *partitionWorkers) *workerLane {
+	return &workerLane{
+		workers:  workers,
+		due:      make(chan laneRetry),
+		retrying: make(map[string]pendingRetry),
+		heldKeys: make(map[string]int),
+	}
+}
+
+// run process messages of the lane and their retries until the lane is closed. The lane stops reading once
+// KafkaCfg.ConsumerBuffer messages are held back, so its worker fills up and the partition is paused.
+func (l *workerLane) run(lane <-chan *kafka.Message) {
+	defer l.cancel()
+
+	stop := l.workers.stop
+	for {
+		in := lane
+		if stop != nil && len(l.held) >= cap(lane) {
+			in = nil
+		}
+
+		select {
+		case msg, ok := <-in:
+			if !ok {
+				return
+			}
+
+			if l.blocked(msg, l.heldKeys) {
+				l.held = append(l.held, msg)
+				l.heldKeys[string(msg.Key)]++
+				continue
+			}
+
+			l.process(laneRetry{msg: msg})
+		case retry := <-l.due:
+			l.process(retry)
+		case <-stop:
+			// Messages left in the lane are still read until it is closed
+			stop = nil
+		}
+	}
+}
+
+// blocked report whether message has to wait behind a retried message or a message held back before it
+func (l *workerLane) blocked(msg *kafka.Message, heldKeys map[string]int) bool {
+	key := string(msg.Key)
+	if key == "" {
+		return len(l.retrying) > 0 || len(heldKeys) > 0
+	}
+
+	_, retrying := l.retrying[key]
+	_, retryingKeyless := l.retrying[""]
+
+	return retrying || retryingKeyless || l.holding > 0 || heldKeys[key] > 0 || heldKeys[""] > 0
+}
+
+// process attempt message, a retried message which is done releases the messages held back behind it
+func (l *workerLane) process(retry laneRetry) {
+	msg := retry.msg
+
+	outcome, backoff := l.workers.retry.attempt(msg, retry.retryCount, retry.holdCount, l.workers.stop)
+	switch outcome {
+	case attemptRetry:
+		l.schedule(laneRetry{msg: msg, retryCount: retry.retryCount + 1, holdCount: retry.holdCount}, backoff, false)
+		return
+	case attemptHold:
+		l.schedule(laneRetry{msg: msg, retryCount: retry.retryCount, holdCount: retry.holdCount + 1}, backoff, true)
+		return
+	case attemptInterrupted:
+		// Message is left uncommitted and consumed again, it keeps holding back its key
+		return
+	}
+
+	l.workers.commit(msg)
+
+	if pending, ok := l.retrying[string(msg.Key)]; ok && (retry.retryCount > 0 || retry.holdCount > 0) {
+		if pending.hold {
+			l.holding--
+		}
+		delete(l.retrying, string(msg.Key))
+		l.release()
+	}
+}
+
+// schedule hand message back to the lane after backoff, later messages are held back behind it meanwhile and every
+// later message of the lane when hold is set
+func (l *workerLane) schedule(retry laneRetry, backoff time.Duration, hold bool) {
+	key := string(retry.msg.Key)
+	if pending, ok := l.retrying[key]; ok && pending.hold {
+		l.holding--
+	}
+	if hold {
+		l.holding++
+	}
+
+	l.retrying[key] = pendingRetry{
+		hold: hold,
+		timer: time.AfterFunc(backoff, func() {
+			select {
+			case l.due <- retry:
+			case <-l.workers.stop:
+			}
+		}),
+	}
+}
+
+// release process the held back messages which are no longer blocked, oldest first
+func (l *workerLane) release() {
+	held := l.held
+	l.held = nil
+	l.heldKeys = make(map[string]int)
+
+	for _, msg := range held {
+		if l.blocked(msg, l.heldKeys) {
+			l.held = append(l.held, msg)
+			l.heldKeys[string(msg.Key)]++
+			continue
+		}
+
+		l.process(laneRetry{msg: msg})
+	}
+}
+
+// cancel stop the backoff timers of the retried messages, they and the messages held back behind them are left
+// uncommitted and consumed again
+func (l *workerLane) cancel() {
+	for _, pending := range l.retrying {
+		pending.timer.Stop()
+	}
+
+	if len(l.retrying) > 0 || len(l.held) > 0 {
+		log.Warn().Msgf(
+			"[workerLane] %d retried and %d held back messages left uncommitted, they are consumed again",
+			len(l.retrying), len(l.held),
+		)
+	}
+}
+
+// add register consumed offset as in flight
+func (o *partitionOffsets) add(offset kafka.Offset) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.inflight = append(o.inflight, offset)
+}
+
+// cancel unregister offset which was just added but not handed over, it is the latest in flight offset
+func (o *partitionOffsets) cancel(offset kafka.Offset) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if last := len(o.inflight) - 1; last >= 0 && o.inflight[last] == offset {
+		o.inflight = o.inflight[:last]
+	}
+}
+
+// done mark offset as processed, commit is called with the next offset to consume once the oldest in flight
+// offsets are done. It runs under the partition lock so commits of a partition never go backwards.
+func (o *partitionOffsets) done(offset kafka.Offset, commit func(next kafka.Offset)) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.finished[offset] = struct{}{}
+
+	var next kafka.Offset
+	for len(o.inflight) > 0 {
+		oldest := o.inflight[0]
+		if _, ok := o.finished[oldest]; !ok {
+			break
+		}
+
+		delete(o.finished, oldest)
+		o.inflight = o.inflight[1:]
+		next = oldest + 1
+	}
+
+	if next > 0 {
+		commit(next)
+	}
+}
+
+// newPartitionKey return key of the topic partition
+func newPartitionKey(tp kafka.TopicPartition) partitionKey {
+	key := partitionKey{partition: tp.Partition}
+	if tp.Topic != nil {
+		key.topic = *tp.Topic
+	}
+
+	return key
+}
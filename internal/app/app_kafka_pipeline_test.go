@@ -0,0 +1,91 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+func TestPartitionOffsetsDone(t *testing.T) {
+	tests := []struct {
+		name     string
+		inflight []kafka.Offset
+		cancel   []kafka.Offset
+		done     []kafka.Offset
+		want     []kafka.Offset
+	}{
+		{
+			name:     "in order",
+			inflight: []kafka.Offset{0, 1, 2},
+			done:     []kafka.Offset{0, 1, 2},
+			want:     []kafka.Offset{1, 2, 3},
+		},
+		{
+			name:     "later offset waits for the oldest",
+			inflight: []kafka.Offset{10, 11, 12},
+			done:     []kafka.Offset{12, 11, 10},
+			want:     []kafka.Offset{13},
+		},
+		{
+			name:     "gap commits up to the unfinished offset",
+			inflight: []kafka.Offset{10, 11, 12, 13},
+			done:     []kafka.Offset{10, 12, 13},
+			want:     []kafka.Offset{11},
+		},
+		{
+			name:     "gap filled later",
+			inflight: []kafka.Offset{10, 11, 12, 13},
+			done:     []kafka.Offset{11, 13, 10, 12},
+			want:     []kafka.Offset{12, 14},
+		},
+		{
+			name:     "offsets which are not contiguous",
+			inflight: []kafka.Offset{5, 9, 20},
+			done:     []kafka.Offset{9, 5, 20},
+			want:     []kafka.Offset{10, 21},
+		},
+		{
+			name:     "nothing done",
+			inflight: []kafka.Offset{1, 2},
+			want:     nil,
+		},
+		{
+			name:     "cancelled last offset does not hold back commits",
+			inflight: []kafka.Offset{1, 2, 3},
+			cancel:   []kafka.Offset{3},
+			done:     []kafka.Offset{1, 2},
+			want:     []kafka.Offset{2, 3},
+		},
+		{
+			name:     "only the last offset is cancelled",
+			inflight: []kafka.Offset{1, 2, 3},
+			cancel:   []kafka.Offset{2},
+			done:     []kafka.Offset{1, 3},
+			want:     []kafka.Offset{2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offsets := &partitionOffsets{finished: make(map[kafka.Offset]struct{})}
+			for _, offset := range tt.inflight {
+				offsets.add(offset)
+			}
+			for _, offset := range tt.cancel {
+				offsets.cancel(offset)
+			}
+
+			var commits []kafka.Offset
+			for _, offset := range tt.done {
+				offsets.done(offset, func(next kafka.Offset) {
+					commits = append(commits, next)
+				})
+			}
+
+			if !reflect.DeepEqual(commits, tt.want) {
+				t.Fatalf("commits = %v, want %v", commits, tt.want)
+			}
+		})
+	}
+}
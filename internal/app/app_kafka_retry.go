@@ -0,0 +1,337 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"message-service-kata/internal/app/infra"
+	"message-service-kata/pkg/cerror"
+	"message-service-kata/pkg/ckafka"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/rs/zerolog/log"
+)
+
+type (
+	// retryPolicy retry failed message in place with exponential backoff, then forward it through the retry
+	// topics of its topic handler and finally to the dead-letter queue so its partition is not blocked
+	retryPolicy struct {
+		args ConsumerHandlerParams
+		// tiers map retry topic to its tier
+		tiers map[string]retryTier
+		host  string
+
+		mu     sync.Mutex
+		jitter *rand.Rand
+	}
+
+	// retryTier is a retry topic of an original topic, its messages are processed delay after they were produced
+	retryTier struct {
+		topic string
+		delay time.Duration
+	}
+
+	// attemptOutcome tell the worker lane what to do with an attempted message
+	attemptOutcome int
+)
+
+const (
+	// attemptDone message is processed or forwarded, its offset can be committed
+	attemptDone attemptOutcome = iota
+	// attemptRetry message is attempted again in place after backoff
+	attemptRetry
+	// attemptHold message failed on an unavailable dependency, it is attempted again after backoff and holds back every
+	// later message of its lane until the dependency recovers
+	attemptHold
+	// attemptInterrupted message is interrupted while forwarding and left uncommitted
+	attemptInterrupted
+)
+
+// newRetryPolicy initiating retry policy of the consumed topics
+func newRetryPolicy(args ConsumerHandlerParams, topics []string) *retryPolicy {
+	r := &retryPolicy{
+		args:   args,
+		tiers:  make(map[string]retryTier),
+		jitter: rand.New(rand.NewSource(time.Now().UnixNano())), //nolint:gosec // jitter does not need crypto rand
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		log.Error().Msgf("[retryPolicy] error while read Hostname: %s", err.Error())
+	}
+	r.host = host
+
+	for _, topic := range topics {
+		handler, _ := args.Handlers.Handler(topic)
+		for _, delay := range handler.Retry.Tiers {
+			r.tiers[retryTopic(topic, delay)] = retryTier{topic: topic, delay: delay}
+		}
+	}
+
+	return r
+}
+
+// retryTopics return the retry topics to subscribe next to the consumed topics
+func (r *retryPolicy) retryTopics() []string {
+	topics := make([]string, 0, len(r.tiers))
+	for topic := range r.tiers {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+
+	return topics
+}
+
+// due return when a message of a retry topic may be processed, its tier delay after it was forwarded. Messages of
+// a retry topic share its delay, so they become due in the order they are stored.
+func (r *retryPolicy) due(msg *kafka.Message) (due time.Time, ok bool) {
+	tier, ok := r.tiers[*msg.TopicPartition.Topic]
+	if !ok {
+		return due, false
+	}
+
+	return msg.Timestamp.Add(tier.delay), true
+}
+
+// attempt process message once. When it fails with in place retries left, the message is expected to be attempted again
+// with retryCount+1 after backoff. A transient infrastructure error does not use the retries, the message is expected
+// to be attempted again with holdCount+1 after backoff, however long the dependency is unavailable, and is never
+// forwarded. Otherwise the failed message is forwarded to the next retry topic or the dead-letter queue, it is
+// interrupted when stop is closed while forwarding and then left uncommitted.
+func (r *retryPolicy) attempt(
+	msg *kafka.Message, retryCount, holdCount int, stop <-chan struct{},
+) (outcome attemptOutcome, backoff time.Duration) {
+	topic := r.originalTopic(*msg.TopicPartition.Topic)
+
+	// Message of a topic without handler has no retry and fails as permanent error
+	handler, _ := r.args.Handlers.Handler(topic)
+
+	err := handleMessage(topic, msg, r.args)
+	if err == nil {
+		return attemptDone, 0
+	}
+
+	log.Error().Any("topic", msg.TopicPartition).Any("value", string(msg.Value)).Any("error", err).Msg("error process kafka message")
+
+	attempts := retryCount + 1
+
+	// Permanent error fails on every attempt, so the message goes straight to the dead-letter queue
+	if cerror.IsPermanent(err) {
+		log.Warn().Any("topic", msg.TopicPartition).Any("value", string(msg.Value)).Msg("Kafka permanent error, skip retries")
+		return r.deadLetter(msg, topic, r.failureHeaders(msg, topic, err, attempts), stop), 0
+	}
+
+	// Every message fails while the dependency is unavailable, so the lane waits for it instead of forwarding them
+	if cerror.IsTransientInfra(err) {
+		backoff = r.backoff(holdCount)
+		log.Warn().
+			Any("topic", msg.TopicPartition).
+			Any("value", string(msg.Value)).
+			Any("hold count", holdCount).
+			Any("backoff", backoff.String()).
+			Msg("Kafka transient infrastructure error, hold lane")
+
+		return attemptHold, backoff
+	}
+
+	// If retry count is less than maxRetryCount, process the message again after backoff.
+	if retryCount < handler.Retry.MaxRetries {
+		backoff = r.backoff(retryCount)
+		log.Warn().
+			Any("topic", msg.TopicPartition).
+			Any("value", string(msg.Value)).
+			Any("retry count", retryCount).
+			Any("backoff", backoff.String()).
+			Msg("Kafka retry")
+
+		return attemptRetry, backoff
+	}
+
+	headers := r.failureHeaders(msg, topic, err, attempts)
+
+	attempt := retryAttempt(msg)
+	if attempt < len(handler.Retry.Tiers) {
+		forwardTopic := retryTopic(topic, handler.Retry.Tiers[attempt])
+		retryHeaders := append(headers, kafka.Header{Key: ckafka.HeaderRetryAttempt, Value: []byte(strconv.Itoa(attempt + 1))})
+
+		err = r.produce(msg, forwardTopic, retryHeaders)
+		if err == nil {
+			log.Warn().Any("topic", forwardTopic).Any("attempt", attempt+1).Any("value", string(msg.Value)).Msg("Kafka forward to retry topic")
+			return attemptDone, 0
+		}
+
+		log.Error().Any("topic", forwardTopic).Any("value", string(msg.Value)).Any("error", err).Msg("error forward message to retry topic")
+	}
+
+	return r.deadLetter(msg, topic, headers, stop), 0
+}
+
+// deadLetter forward the message to the dead-letter queue of its original topic so its offset can be committed.
+// Producing is retried until it is delivered, it is interrupted when stop is closed first.
+func (r *retryPolicy) deadLetter(msg *kafka.Message, topic string, headers []kafka.Header, stop <-chan struct{}) attemptOutcome {
+	dlqTopic := ckafka.DeadLetterTopic(topic)
+
+	for retryCount := 0; ; retryCount++ {
+		err := r.produce(msg, dlqTopic, headers)
+		if err == nil {
+			log.Warn().Any("topic", dlqTopic).Any("value", string(msg.Value)).Msg("Kafka forward to dead-letter queue")
+			return attemptDone
+		}
+
+		log.Error().Any("topic", dlqTopic).Any("value", string(msg.Value)).Any("error", err).Msg("error process produce dlq message")
+
+		if !sleep(stop, r.backoff(retryCount)) {
+			return attemptInterrupted
+		}
+	}
+}
+
+// failureHeaders return headers of the message with its failure metadata, the original position is kept
+// from the headers of a message which was already forwarded
+func (r *retryPolicy) failureHeaders(msg *kafka.Message, topic string, err error, attempts int) []kafka.Header {
+	headers := make([]kafka.Header, 0, len(msg.Headers)+len(ckafka.FailureHeaders))
+	for _, header := range msg.Headers {
+		if _, ok := ckafka.FailureHeaders[header.Key]; !ok {
+			headers = append(headers, header)
+		}
+	}
+
+	partition := strconv.Itoa(int(msg.TopicPartition.Partition))
+	offset := msg.TopicPartition.Offset.String()
+	if value, ok := headerValue(msg, ckafka.HeaderOriginalPartition); ok {
+		partition = value
+	}
+	if value, ok := headerValue(msg, ckafka.HeaderOriginalOffset); ok {
+		offset = value
+	}
+
+	if value, ok := headerValue(msg, ckafka.HeaderAttempts); ok {
+		previous, errs := strconv.Atoi(value)
+		if errs == nil {
+			attempts += previous
+		}
+	}
+
+	return append(headers,
+		kafka.Header{Key: ckafka.HeaderOriginalTopic, Value: []byte(topic)},
+		kafka.Header{Key: ckafka.HeaderOriginalPartition, Value: []byte(partition)},
+		kafka.Header{Key: ckafka.HeaderOriginalOffset, Value: []byte(offset)},
+		kafka.Header{Key: ckafka.HeaderError, Value: []byte(err.Error())},
+		kafka.Header{Key: ckafka.HeaderErrorClass, Value: []byte(cerror.ClassOf(err))},
+		kafka.Header{Key: ckafka.HeaderErrorType, Value: []byte(errorType(err))},
+		kafka.Header{Key: ckafka.HeaderAttempts, Value: []byte(strconv.Itoa(attempts))},
+		kafka.Header{Key: ckafka.HeaderFailedAt, Value: []byte(time.Now().Format(time.RFC3339Nano))},
+		kafka.Header{Key: ckafka.HeaderConsumerHost, Value: []byte(r.host)},
+	)
+}
+
+// backoff return exponential backoff of the retry with jitter, between half and the full delay
+func (r *retryPolicy) backoff(retryCount int) time.Duration {
+	delay := r.args.KafkaCfg.RetryBackoffMax
+	if retryCount < 32 {
+		if exp := r.args.KafkaCfg.RetryBackoffBase << uint(retryCount); exp > 0 && exp < delay {
+			delay = exp
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return delay/2 + time.Duration(r.jitter.Int63n(int64(delay/2)+1))
+}
+
+// produce copy of the message to topic and wait for its delivery, so the offset is only committed
+// once the message is stored in the topic
+func (r *retryPolicy) produce(msg *kafka.Message, topic string, headers []kafka.Header) error {
+	deliveryCh := make(chan kafka.Event, 1)
+	err := r.args.Producer.Produce(&kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+		Value:          msg.Value,
+		Key:            msg.Key,
+		Headers:        headers,
+	}, deliveryCh)
+	if err != nil {
+		return err
+	}
+
+	delivered, ok := (<-deliveryCh).(*kafka.Message)
+	if !ok {
+		return fmt.Errorf("unexpected delivery report of topic %s", topic)
+	}
+
+	return delivered.TopicPartition.Error
+}
+
+// originalTopic return the topic whose handler process messages of topic, which is itself unless it is a retry topic
+func (r *retryPolicy) originalTopic(topic string) string {
+	if tier, ok := r.tiers[topic]; ok {
+		return tier.topic
+	}
+
+	return topic
+}
+
+// retryTopic return name of the retry topic of topic with the given delay, e.g. message.publish-retry-5s
+func retryTopic(topic string, delay time.Duration) string {
+	return fmt.Sprintf("%s-retry-%s", topic, infra.RetryTierName(delay))
+}
+
+// retryAttempt return the number of retry topics the message went through, 0 when it was never retried
+func retryAttempt(msg *kafka.Message) int {
+	value, ok := headerValue(msg, ckafka.HeaderRetryAttempt)
+	if !ok {
+		return 0
+	}
+
+	attempt, err := strconv.Atoi(value)
+	if err != nil || attempt < 0 {
+		return 0
+	}
+
+	return attempt
+}
+
+// headerValue return value of the last header of the message with the given key
+func headerValue(msg *kafka.Message, key string) (value string, ok bool) {
+	for _, header := range msg.Headers {
+		if header.Key == key {
+			value, ok = string(header.Value), true
+		}
+	}
+
+	return value, ok
+}
+
+// errorType return type of the innermost error, e.g. *json.SyntaxError or *pq.Error
+func errorType(err error) string {
+	for {
+		unwrapped := errors.Unwrap(err)
+		if unwrapped == nil {
+			return fmt.Sprintf("%T", err)
+		}
+		err = unwrapped
+	}
+}
+
+// sleep wait for d unless stop is closed first, it returns false when stopped
+func sleep(stop <-chan struct{}, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-stop:
+		return false
+	}
+}
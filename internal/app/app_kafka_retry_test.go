@@ -0,0 +1,329 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	kafkaCtrl "message-service-kata/internal/app/controller/kafka"
+	"message-service-kata/internal/app/infra"
+	"message-service-kata/pkg/cerror"
+	"message-service-kata/pkg/ckafka"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	r := &retryPolicy{
+		args: ConsumerHandlerParams{KafkaCfg: &infra.KafkaCfg{
+			RetryBackoffBase: 100 * time.Millisecond,
+			RetryBackoffMax:  2 * time.Second,
+		}},
+		jitter: rand.New(rand.NewSource(1)), //nolint:gosec // jitter does not need crypto rand
+	}
+
+	tests := []struct {
+		name       string
+		retryCount int
+		wantDelay  time.Duration
+	}{
+		{name: "first retry is the base", retryCount: 0, wantDelay: 100 * time.Millisecond},
+		{name: "doubled on every retry", retryCount: 1, wantDelay: 200 * time.Millisecond},
+		{name: "third retry", retryCount: 3, wantDelay: 800 * time.Millisecond},
+		{name: "capped at the max", retryCount: 5, wantDelay: 2 * time.Second},
+		{name: "shift overflow is capped", retryCount: 40, wantDelay: 2 * time.Second},
+		{name: "huge retry count is capped", retryCount: 1 << 20, wantDelay: 2 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				got := r.backoff(tt.retryCount)
+				if got < tt.wantDelay/2 || got > tt.wantDelay {
+					t.Fatalf("backoff(%d) = %s, want between %s and %s", tt.retryCount, got, tt.wantDelay/2, tt.wantDelay)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryAttempt(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []kafka.Header
+		want    int
+	}{
+		{name: "never retried", want: 0},
+		{
+			name:    "other headers",
+			headers: []kafka.Header{{Key: ckafka.HeaderAttempts, Value: []byte("3")}},
+			want:    0,
+		},
+		{
+			name:    "retry attempt",
+			headers: []kafka.Header{{Key: ckafka.HeaderRetryAttempt, Value: []byte("2")}},
+			want:    2,
+		},
+		{
+			name: "last header wins",
+			headers: []kafka.Header{
+				{Key: ckafka.HeaderRetryAttempt, Value: []byte("1")},
+				{Key: ckafka.HeaderRetryAttempt, Value: []byte("2")},
+			},
+			want: 2,
+		},
+		{
+			name:    "invalid value",
+			headers: []kafka.Header{{Key: ckafka.HeaderRetryAttempt, Value: []byte("two")}},
+			want:    0,
+		},
+		{
+			name:    "negative value",
+			headers: []kafka.Header{{Key: ckafka.HeaderRetryAttempt, Value: []byte("-1")}},
+			want:    0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryAttempt(&kafka.Message{Headers: tt.headers}); got != tt.want {
+				t.Fatalf("retryAttempt() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryTopic(t *testing.T) {
+	tests := []struct {
+		delay time.Duration
+		want  string
+	}{
+		{delay: 5 * time.Second, want: "message.publish-retry-5s"},
+		{delay: time.Minute, want: "message.publish-retry-1m"},
+		{delay: 2 * time.Hour, want: "message.publish-retry-2h"},
+		{delay: 1500 * time.Millisecond, want: "message.publish-retry-1500ms"},
+		{delay: 90 * time.Second, want: "message.publish-retry-90s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := retryTopic("message.publish", tt.delay); got != tt.want {
+				t.Fatalf("retryTopic() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkerLaneBlocked(t *testing.T) {
+	tests := []struct {
+		name     string
+		retrying map[string]bool
+		heldKeys map[string]int
+		key      string
+		want     bool
+	}{
+		{name: "idle lane", key: "a", want: false},
+		{name: "idle lane keyless", key: "", want: false},
+		{name: "same key retrying", retrying: map[string]bool{"a": false}, key: "a", want: true},
+		{name: "other key retrying", retrying: map[string]bool{"b": false}, key: "a", want: false},
+		{name: "same key held", heldKeys: map[string]int{"a": 1}, key: "a", want: true},
+		{name: "other key held", heldKeys: map[string]int{"b": 1}, key: "a", want: false},
+		{name: "keyless behind retrying key", retrying: map[string]bool{"b": false}, key: "", want: true},
+		{name: "keyless behind held key", heldKeys: map[string]int{"b": 1}, key: "", want: true},
+		{name: "key behind retrying keyless", retrying: map[string]bool{"": false}, key: "a", want: true},
+		{name: "key behind held keyless", heldKeys: map[string]int{"": 1}, key: "a", want: true},
+		{name: "key behind other key holding the lane", retrying: map[string]bool{"b": true}, key: "a", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lane := &workerLane{retrying: make(map[string]pendingRetry)}
+			for key, hold := range tt.retrying {
+				lane.retrying[key] = pendingRetry{hold: hold}
+				if hold {
+					lane.holding++
+				}
+			}
+
+			heldKeys := tt.heldKeys
+			if heldKeys == nil {
+				heldKeys = make(map[string]int)
+			}
+
+			if got := lane.blocked(&kafka.Message{Key: []byte(tt.key)}, heldKeys); got != tt.want {
+				t.Fatalf("blocked(%q) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyFailureHeaders(t *testing.T) {
+	topic := "message.publish-retry-5s"
+	r := &retryPolicy{host: "consumer-1"}
+
+	tests := []struct {
+		name     string
+		headers  []kafka.Header
+		err      error
+		attempts int
+		want     map[string]string
+	}{
+		{
+			name:     "first failure",
+			headers:  []kafka.Header{{Key: "trace-id", Value: []byte("abc")}},
+			err:      cerror.Permanent(&json.SyntaxError{}),
+			attempts: 1,
+			want: map[string]string{
+				"trace-id":                     "abc",
+				ckafka.HeaderOriginalTopic:     "message.publish",
+				ckafka.HeaderOriginalPartition: "3",
+				ckafka.HeaderOriginalOffset:    "42",
+				ckafka.HeaderErrorClass:        "permanent",
+				ckafka.HeaderErrorType:         "*json.SyntaxError",
+				ckafka.HeaderAttempts:          "1",
+				ckafka.HeaderConsumerHost:      "consumer-1",
+			},
+		},
+		{
+			name: "forwarded message keeps its original position and adds its attempts",
+			headers: []kafka.Header{
+				{Key: ckafka.HeaderOriginalPartition, Value: []byte("0")},
+				{Key: ckafka.HeaderOriginalOffset, Value: []byte("7")},
+				{Key: ckafka.HeaderAttempts, Value: []byte("4")},
+				{Key: ckafka.HeaderErrorClass, Value: []byte("permanent")},
+				{Key: ckafka.HeaderRetryAttempt, Value: []byte("1")},
+			},
+			err:      fmt.Errorf("while process: %w", errors.New("boom")),
+			attempts: 3,
+			want: map[string]string{
+				ckafka.HeaderOriginalTopic:     "message.publish",
+				ckafka.HeaderOriginalPartition: "0",
+				ckafka.HeaderOriginalOffset:    "7",
+				ckafka.HeaderErrorClass:        "retryable",
+				ckafka.HeaderErrorType:         "*errors.errorString",
+				ckafka.HeaderAttempts:          "7",
+				ckafka.HeaderConsumerHost:      "consumer-1",
+			},
+		},
+		{
+			name:     "invalid previous attempts are ignored",
+			headers:  []kafka.Header{{Key: ckafka.HeaderAttempts, Value: []byte("many")}},
+			err:      cerror.TransientInfra(errors.New("connection refused")),
+			attempts: 2,
+			want: map[string]string{
+				ckafka.HeaderOriginalTopic:     "message.publish",
+				ckafka.HeaderOriginalPartition: "3",
+				ckafka.HeaderOriginalOffset:    "42",
+				ckafka.HeaderErrorClass:        "transient_infra",
+				ckafka.HeaderErrorType:         "*errors.errorString",
+				ckafka.HeaderAttempts:          "2",
+				ckafka.HeaderConsumerHost:      "consumer-1",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := &kafka.Message{
+				TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 3, Offset: 42},
+				Headers:        tt.headers,
+			}
+
+			headers := r.failureHeaders(msg, "message.publish", tt.err, tt.attempts)
+
+			got := make(map[string]string, len(headers))
+			for _, header := range headers {
+				if _, ok := got[header.Key]; ok {
+					t.Fatalf("failureHeaders() header %s is set twice", header.Key)
+				}
+				got[header.Key] = string(header.Value)
+			}
+
+			if _, ok := got[ckafka.HeaderRetryAttempt]; ok {
+				t.Fatalf("failureHeaders() kept header %s", ckafka.HeaderRetryAttempt)
+			}
+
+			if got[ckafka.HeaderError] != tt.err.Error() {
+				t.Fatalf("failureHeaders() %s = %q, want %q", ckafka.HeaderError, got[ckafka.HeaderError], tt.err.Error())
+			}
+
+			if _, err := time.Parse(time.RFC3339Nano, got[ckafka.HeaderFailedAt]); err != nil {
+				t.Fatalf("failureHeaders() %s = %q: %v", ckafka.HeaderFailedAt, got[ckafka.HeaderFailedAt], err)
+			}
+
+			for key, want := range tt.want {
+				if got[key] != want {
+					t.Fatalf("failureHeaders() %s = %q, want %q", key, got[key], want)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryPolicyAttempt(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		retryCount  int
+		holdCount   int
+		wantOutcome attemptOutcome
+		wantMax     time.Duration
+	}{
+		{name: "processed", err: nil, wantOutcome: attemptDone},
+		{name: "retryable with retries left", err: errors.New("boom"), retryCount: 1, wantOutcome: attemptRetry, wantMax: 200 * time.Millisecond},
+		{name: "transient infra holds", err: cerror.TransientInfra(errors.New("connection refused")), wantOutcome: attemptHold, wantMax: 100 * time.Millisecond},
+		{
+			name:        "transient infra does not use the retries",
+			err:         cerror.TransientInfra(errors.New("connection refused")),
+			retryCount:  5,
+			holdCount:   2,
+			wantOutcome: attemptHold,
+			wantMax:     400 * time.Millisecond,
+		},
+		{
+			name:        "transient infra backoff is capped",
+			err:         cerror.TransientInfra(errors.New("connection refused")),
+			holdCount:   100,
+			wantOutcome: attemptHold,
+			wantMax:     time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry, err := kafkaCtrl.NewHandlerRegistry(kafkaCtrl.HandlerRegistryImpl{Handlers: []kafkaCtrl.TopicHandler{{
+				Topic:       "message.publish",
+				Payload:     map[string]interface{}{},
+				Retry:       kafkaCtrl.RetryPolicy{MaxRetries: 3},
+				Concurrency: 1,
+				Handle: func(ctx context.Context, message *kafka.Message, payload interface{}) error {
+					return tt.err
+				},
+			}}})
+			if err != nil {
+				t.Fatalf("NewHandlerRegistry() error = %v", err)
+			}
+
+			r := newRetryPolicy(ConsumerHandlerParams{
+				KafkaCfg: &infra.KafkaCfg{RetryBackoffBase: 100 * time.Millisecond, RetryBackoffMax: time.Second},
+				Handlers: registry,
+			}, registry.Topics())
+
+			topic := "message.publish"
+			msg := &kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topic}, Value: []byte(`{}`)}
+
+			outcome, backoff := r.attempt(msg, tt.retryCount, tt.holdCount, make(chan struct{}))
+			if outcome != tt.wantOutcome {
+				t.Fatalf("attempt() outcome = %d, want %d", outcome, tt.wantOutcome)
+			}
+
+			if backoff < tt.wantMax/2 || backoff > tt.wantMax {
+				t.Fatalf("attempt() backoff = %s, want between %s and %s", backoff, tt.wantMax/2, tt.wantMax)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package app
+
+import (
+	"context"
+	"os/signal"
+	"time"
+
+	"message-service-kata/internal/app/infra"
+	"message-service-kata/internal/app/service"
+	"message-service-kata/pkg/di"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/rs/zerolog/log"
+)
+
+// outboxFlushTimeoutMs is the longest wait for messages left in the producer queue before exiting
+const outboxFlushTimeoutMs = 10000
+
+// StartOutboxRelay - function to publish pending outbox messages until exit signal. The relay waits for the poll
+// interval after a round which did not fill a whole batch, a full batch means more messages are pending.
+func StartOutboxRelay() error {
+	ctx, stop := signal.NotifyContext(context.Background(), exitSigs...)
+	defer stop()
+
+	return di.Invoke(func(outboxSvc service.OutboxSvc, producer *kafka.Producer, cfg *infra.KafkaCfg) {
+		defer producer.Close()
+		defer producer.Flush(outboxFlushTimeoutMs)
+
+		log.Info().
+			Any("poll_interval", cfg.OutboxPollInterval.String()).
+			Any("batch_size", cfg.OutboxBatchSize).
+			Msg("outbox relay started")
+
+		for ctx.Err() == nil {
+			result, err := outboxSvc.RelayOutbox(ctx)
+			if err == nil && result.Sent > 0 {
+				log.Info().Any("result", result).Msg("outbox messages relayed")
+			}
+
+			if err == nil && result.Failed == 0 && result.Claimed == cfg.OutboxBatchSize {
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+			case <-time.After(cfg.OutboxPollInterval):
+			}
+		}
+
+		log.Info().Msg("outbox relay stopped")
+	})
+}
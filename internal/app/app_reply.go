@@ -0,0 +1,101 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	kafkaCtrl "message-service-kata/internal/app/controller/kafka"
+	"message-service-kata/internal/app/infra"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/rs/zerolog/log"
+	"go.uber.org/dig"
+)
+
+const (
+	// replyPollTimeout is the longest wait for a reply before checking shutdown again
+	replyPollTimeout = time.Second
+	// replySeekTimeoutMs is the timeout of looking up the offsets of the replies produced since startup
+	replySeekTimeoutMs = 5000
+	// replyClockSkew is how long before startup replies are consumed from, to allow producer clock skew
+	replyClockSkew = 5 * time.Second
+)
+
+type (
+	// ReplyConsumerParams is a reply consumer handler dependencies
+	ReplyConsumerParams struct {
+		dig.In
+		Consumer  *infra.ReplyConsumer
+		KafkaCfg  *infra.KafkaCfg
+		KafkaCtrl kafkaCtrl.Processor
+	}
+)
+
+// startReplyConsumer consume chatbot replies inside the rest service until shutdown. Offsets are not committed
+// because replies are only awaited by requests in flight of this instance.
+func startReplyConsumer(args ReplyConsumerParams, shutdownCh <-chan struct{}) {
+	defer func() {
+		if err := args.Consumer.Close(); err != nil {
+			log.Error().Msgf("reply consumer.Close: %s", err.Error())
+		}
+	}()
+
+	// Replies produced between startup and the partition assignment would be skipped by the latest offset
+	rebalance := func(c *kafka.Consumer, event kafka.Event) error {
+		err := rebalanceCallback(c, event)
+
+		if ev, ok := event.(kafka.AssignedPartitions); ok && len(ev.Partitions) > 0 {
+			assignSince(c, ev.Partitions, args.Consumer.StartedAt.Add(-replyClockSkew))
+		}
+
+		return err
+	}
+
+	err := args.Consumer.Subscribe(args.KafkaCfg.ReplyTopic, rebalance)
+	if err != nil {
+		log.Error().Msgf("Subscribe reply topic: %s", err.Error())
+		return
+	}
+
+	for {
+		select {
+		case <-shutdownCh:
+			log.Info().Msg("shutdown reply consumer")
+			return
+		default:
+			msg, err := args.Consumer.ReadMessage(replyPollTimeout)
+			if err != nil {
+				var kafkaErr kafka.Error
+				if !errors.As(err, &kafkaErr) || kafkaErr.Code() != kafka.ErrTimedOut {
+					log.Error().Msgf("ReadMessage reply: %s", err.Error())
+				}
+				continue
+			}
+
+			// A reply which can not be handled only fails its waiting request, keep consuming the others
+			_ = args.KafkaCtrl.ProcessReply(context.Background(), msg)
+		}
+	}
+}
+
+// assignSince assign the partitions from the first message produced at or after since, partitions without such
+// message start from their end. The default assignment is kept when the offsets can not be looked up.
+func assignSince(c *kafka.Consumer, partitions []kafka.TopicPartition, since time.Time) {
+	times := make([]kafka.TopicPartition, len(partitions))
+	for i, tp := range partitions {
+		tp.Offset = kafka.Offset(since.UnixMilli())
+		times[i] = tp
+	}
+
+	offsets, err := c.OffsetsForTimes(times, replySeekTimeoutMs)
+	if err != nil {
+		log.Error().Msgf("[assignSince] error while OffsetsForTimes, consume from latest: %s", err.Error())
+		return
+	}
+
+	err = c.Assign(offsets)
+	if err != nil {
+		log.Error().Msgf("[assignSince] error while Assign, consume from latest: %s", err.Error())
+	}
+}
@@ -9,7 +9,9 @@ import (
 	"syscall"
 	"time"
 
+	controller "message-service-kata/internal/app/controller/rest"
 	"message-service-kata/internal/app/infra"
+	"message-service-kata/internal/app/service"
 	"message-service-kata/pkg/di"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
@@ -27,6 +29,35 @@ func StartRestServer() {
 
 	signal.Notify(exitCh, exitSigs...)
 
+	// Keep cached chatbot responses reported by health check up to date
+	if err := di.Invoke(func(catalog service.ResponseCatalog) {
+		go catalog.Run(shutdownCh)
+	}); err != nil {
+		log.Error().Msgf("Invoke: %s", err.Error())
+	}
+
+	// Refresh cached chatbot responses of this instance when they are changed
+	if err := di.Invoke(func(args InvalidationConsumerParams) {
+		go startInvalidationConsumer(args, shutdownCh)
+	}); err != nil {
+		log.Error().Msgf("Invoke: %s", err.Error())
+	}
+
+	// Consume chatbot replies awaited by the chat endpoint
+	if err := di.Invoke(func(kafkaCfg *infra.KafkaCfg) {
+		if kafkaCfg.ReplyTopic == "" {
+			return
+		}
+
+		if err := di.Invoke(func(args ReplyConsumerParams) {
+			go startReplyConsumer(args, shutdownCh)
+		}); err != nil {
+			log.Error().Msgf("Invoke: %s", err.Error())
+		}
+	}); err != nil {
+		log.Error().Msgf("Invoke: %s", err.Error())
+	}
+
 	go func() {
 		defer func() { exitCh <- syscall.SIGTERM }()
 		if err := di.Invoke(startRestApp); err != nil {
@@ -71,8 +102,26 @@ func StartConsumerServer(topic string) {
 
 	signal.Notify(exitCh, exitSigs...)
 
+	// Keep cached chatbot responses fresh in case invalidation event is missed
+	if err := di.Invoke(func(catalog service.ResponseCatalog) {
+		go catalog.Run(shutdownCh)
+	}); err != nil {
+		log.Error().Msgf("Invoke: %s", err.Error())
+	}
+
+	// Refresh cached chatbot responses of this instance when they are changed
+	if err := di.Invoke(func(args InvalidationConsumerParams) {
+		go startInvalidationConsumer(args, shutdownCh)
+	}); err != nil {
+		log.Error().Msgf("Invoke: %s", err.Error())
+	}
+
+	// Closed once the consumer stopped reading and its in flight messages are committed
+	consumerDone := make(chan struct{})
+
 	go func() {
 		defer func() { exitCh <- syscall.SIGTERM }()
+		defer close(consumerDone)
 		if err := di.Invoke(func(args ConsumerHandlerParams) {
 			startConsumer(args, shutdownCh, errCh, topic)
 		}); err != nil {
@@ -89,7 +138,9 @@ func StartConsumerServer(topic string) {
 
 	close(shutdownCh)
 
-	if err := di.Invoke(gracefulConsumerShutdown); err != nil {
+	if err := di.Invoke(func(pg *sql.DB, consumer *kafka.Consumer) {
+		gracefulConsumerShutdown(pg, consumer, consumerDone)
+	}); err != nil {
 		log.Error().Msgf("Invoke: %s", err.Error())
 	}
 }
@@ -97,6 +148,8 @@ func StartConsumerServer(topic string) {
 func gracefulRestShutdown(
 	e *echo.Echo,
 	pg *sql.DB,
+	gatewayCtrl controller.GatewayCtrl,
+	deadLetterReader *infra.DeadLetterReader,
 ) {
 	timeOutTime := 60 * time.Second
 
@@ -105,10 +158,20 @@ func gracefulRestShutdown(
 
 	log.Info().Msg("shutting down rest server")
 
+	// Hijacked websocket connections are not closed by echo shutdown
+	gatewayCtrl.Shutdown(ctx)
+
 	if err := pg.Close(); err != nil {
 		log.Error().Msgf("postgres close: %s", err.Error())
 	}
 
+	// Waits for the dead-letter read in progress, if any
+	deadLetterReader.Lock()
+	if err := deadLetterReader.Close(); err != nil {
+		log.Error().Msgf("dead-letter reader close: %s", err.Error())
+	}
+	deadLetterReader.Unlock()
+
 	if err := e.Shutdown(ctx); err != nil {
 		log.Error().Msgf("echo shutdown: %s", err.Error())
 	}
@@ -119,9 +182,20 @@ func gracefulRestShutdown(
 func gracefulConsumerShutdown(
 	pg *sql.DB,
 	consumer *kafka.Consumer,
+	consumerDone <-chan struct{},
 ) {
+	timeOutTime := 60 * time.Second
+
 	log.Info().Msg("shutting down consumer server")
 
+	// Offsets of in flight messages are committed before the consumer is closed
+	select {
+	case <-consumerDone:
+		log.Info().Msg("consumer in flight messages drained")
+	case <-time.After(timeOutTime):
+		log.Error().Msgf("consumer in flight messages not drained after %s", timeOutTime)
+	}
+
 	if err := consumer.Close(); err != nil {
 		log.Error().Msgf("consumer.Close: %s", err.Error())
 	}
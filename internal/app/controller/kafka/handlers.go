@@ -0,0 +1,43 @@
+package kafka
+
+import (
+	"context"
+
+	"message-service-kata/internal/app/infra"
+	"message-service-kata/pkg/ckafka"
+	"message-service-kata/pkg/domain/entities"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// NewPublishMessageHandler declare handler of published chatbot messages, retried with the configured retry topics
+func NewPublishMessageHandler(processor Processor, cfg *infra.KafkaCfg) TopicHandlerOut {
+	return TopicHandlerOut{Handler: TopicHandler{
+		Topic:   string(entities.TopicPublishMessage),
+		Payload: entities.MessageData{},
+		Retry: RetryPolicy{
+			MaxRetries: cfg.MaxConsumerRetries,
+			Tiers:      cfg.RetryTiers,
+		},
+		Concurrency: cfg.ConsumerWorkers,
+		Handle: func(ctx context.Context, message *kafka.Message, payload interface{}) error {
+			return processor.ProcessMessage(ctx, message, payload.(entities.MessageData))
+		},
+	}}
+}
+
+// NewCheckoutHandler declare handler of portfolio checkout events, retried like published chatbot messages
+func NewCheckoutHandler(processor Processor, cfg *infra.KafkaCfg) TopicHandlerOut {
+	return TopicHandlerOut{Handler: TopicHandler{
+		Topic:   ckafka.TopicProductPorfolioCheckout,
+		Payload: entities.CheckoutEvent{},
+		Retry: RetryPolicy{
+			MaxRetries: cfg.MaxConsumerRetries,
+			Tiers:      cfg.RetryTiers,
+		},
+		Concurrency: cfg.ConsumerWorkers,
+		Handle: func(ctx context.Context, message *kafka.Message, payload interface{}) error {
+			return processor.ProcessCheckout(ctx, message, payload.(entities.CheckoutEvent))
+		},
+	}}
+}
@@ -3,8 +3,10 @@ package kafka
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 
 	"message-service-kata/internal/app/service"
+	"message-service-kata/pkg/cerror"
 	"message-service-kata/pkg/domain/entities"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
@@ -16,12 +18,17 @@ type (
 	// ProcessorImpl Process a set of kafka processors.
 	ProcessorImpl struct {
 		dig.In
-		MessageSvc service.MessageSvc
+		MessageSvc  service.MessageSvc
+		ResponseSvc service.ResponseSvc
+		ChatSvc     service.ChatSvc
 	}
 
 	// Processor implementator for processing messages.
 	Processor interface {
-		ProcessMessage(ctx context.Context, message *kafka.Message) (err error)
+		ProcessMessage(ctx context.Context, message *kafka.Message, data entities.MessageData) (err error)
+		ProcessResponseInvalidation(ctx context.Context, message *kafka.Message) (err error)
+		ProcessReply(ctx context.Context, message *kafka.Message) (err error)
+		ProcessCheckout(ctx context.Context, message *kafka.Message, event entities.CheckoutEvent) (err error)
 	}
 )
 
@@ -31,21 +38,87 @@ func NewProcessor(impl ProcessorImpl) Processor {
 }
 
 // ProcessMessage impelements interface processor
-func (op *ProcessorImpl) ProcessMessage(ctx context.Context, message *kafka.Message) (err error) {
+func (op *ProcessorImpl) ProcessMessage(ctx context.Context, message *kafka.Message, data entities.MessageData) (err error) {
 	defer func() {
 		if err != nil {
 			log.Error().Msgf("[ProcessMessage] any error with msg : %v", err)
 		}
 	}()
 
-	var data entities.MessageData
+	// Message published before message ID was introduced is identified by its kafka position
+	if data.ID == "" {
+		data.ID = fmt.Sprintf("%s-%d-%d", *message.TopicPartition.Topic, message.TopicPartition.Partition, message.TopicPartition.Offset)
+	}
 
-	err = json.Unmarshal(message.Value, &data)
+	err = op.MessageSvc.ProcessMessage(ctx, data)
 	if err != nil {
 		return err
 	}
 
-	err = op.MessageSvc.ProcessMessage(ctx, data)
+	return nil
+}
+
+// ProcessResponseInvalidation impelements interface processor, refresh cached chatbot responses of this instance
+func (op *ProcessorImpl) ProcessResponseInvalidation(ctx context.Context, message *kafka.Message) (err error) {
+	defer func() {
+		if err != nil {
+			log.Error().Msgf("[ProcessResponseInvalidation] any error with msg : %v", err)
+		}
+	}()
+
+	var event entities.ResponseInvalidateEvent
+
+	// Payload which can not be decoded fails on every attempt
+	err = json.Unmarshal(message.Value, &event)
+	if err != nil {
+		return cerror.Permanent(err)
+	}
+
+	log.Info().Msgf("[ProcessResponseInvalidation] chatbot response %d %s", event.ResponseID, event.Action)
+
+	err = op.ResponseSvc.RefreshResponses(ctx)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ProcessReply impelements interface processor, hand chatbot reply over to the request waiting for it
+func (op *ProcessorImpl) ProcessReply(ctx context.Context, message *kafka.Message) (err error) {
+	defer func() {
+		if err != nil {
+			log.Error().Msgf("[ProcessReply] any error with msg : %v", err)
+		}
+	}()
+
+	var reply entities.MessageReplyEvent
+
+	// Payload which can not be decoded fails on every attempt
+	err = json.Unmarshal(message.Value, &reply)
+	if err != nil {
+		return cerror.Permanent(err)
+	}
+
+	err = op.ChatSvc.DispatchReply(ctx, reply)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ProcessCheckout impelements interface processor, send templated message to the user of the checkout
+func (op *ProcessorImpl) ProcessCheckout(
+	ctx context.Context, message *kafka.Message, event entities.CheckoutEvent,
+) (err error) {
+	defer func() {
+		if err != nil {
+			log.Error().Msgf("[ProcessCheckout] any error with msg : %v", err)
+		}
+	}()
+
+	err = op.MessageSvc.ProcessCheckout(ctx, event)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,152 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"message-service-kata/internal/app/infra"
+	"message-service-kata/pkg/cerror"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"go.uber.org/dig"
+)
+
+type (
+	// HandleFunc process the decoded payload of a consumed message, payload has the type of TopicHandler.Payload
+	HandleFunc func(ctx context.Context, message *kafka.Message, payload interface{}) (err error)
+
+	// RetryPolicy declare how a failed message of a topic is retried. MaxRetries is the number of in place
+	// retries, Tiers are the delays of the retry topics the message goes through before the dead-letter queue.
+	RetryPolicy struct {
+		MaxRetries int
+		Tiers      []time.Duration
+	}
+
+	// TopicHandler declare how messages of a topic are consumed. Payload is a zero value of the message payload,
+	// every message is decoded into a new value of its type. Concurrency is the number of workers per partition.
+	TopicHandler struct {
+		Topic       string
+		Payload     interface{}
+		Retry       RetryPolicy
+		Concurrency int
+		Handle      HandleFunc
+	}
+
+	// TopicHandlerOut provide topic handler to the handler registry
+	TopicHandlerOut struct {
+		dig.Out
+		Handler TopicHandler `group:"topic_handlers"`
+	}
+
+	// HandlerRegistryImpl collect every provided topic handler
+	HandlerRegistryImpl struct {
+		dig.In
+		Handlers []TopicHandler `group:"topic_handlers"`
+	}
+
+	// HandlerRegistry interfacing topic handler registry function
+	HandlerRegistry interface {
+		Handler(topic string) (handler TopicHandler, ok bool)
+		Topics() []string
+	}
+
+	// handlerRegistry implementing registry of the validated topic handlers
+	handlerRegistry struct {
+		handlers map[string]TopicHandler
+		topics   []string
+	}
+)
+
+// NewHandlerRegistry initiating registry of the provided topic handlers, invalid or duplicate handler is rejected
+func NewHandlerRegistry(impl HandlerRegistryImpl) (HandlerRegistry, error) {
+	registry := &handlerRegistry{
+		handlers: make(map[string]TopicHandler, len(impl.Handlers)),
+		topics:   make([]string, 0, len(impl.Handlers)),
+	}
+
+	for _, handler := range impl.Handlers {
+		if _, ok := registry.handlers[handler.Topic]; ok {
+			return nil, fmt.Errorf("topic %s: duplicate handler", handler.Topic)
+		}
+
+		err := handler.validate()
+		if err != nil {
+			return nil, fmt.Errorf("topic %s: %w", handler.Topic, err)
+		}
+
+		registry.handlers[handler.Topic] = handler
+		registry.topics = append(registry.topics, handler.Topic)
+	}
+	sort.Strings(registry.topics)
+
+	return registry, nil
+}
+
+// Handler return handler of the topic
+func (r *handlerRegistry) Handler(topic string) (handler TopicHandler, ok bool) {
+	handler, ok = r.handlers[topic]
+	return handler, ok
+}
+
+// Topics return the registered topics in alphabetical order
+func (r *handlerRegistry) Topics() []string {
+	topics := make([]string, len(r.topics))
+	copy(topics, r.topics)
+
+	return topics
+}
+
+// Process decode payload of the message and hand it over to the handler
+func (h *TopicHandler) Process(ctx context.Context, message *kafka.Message) (err error) {
+	payload := reflect.New(reflect.TypeOf(h.Payload))
+
+	// Payload which can not be decoded fails on every attempt
+	err = json.Unmarshal(message.Value, payload.Interface())
+	if err != nil {
+		return cerror.Permanent(err)
+	}
+
+	return h.Handle(ctx, message, payload.Elem().Interface())
+}
+
+// validate check the handler declaration
+func (h *TopicHandler) validate() error {
+	if h.Topic == "" {
+		return errors.New("topic is required")
+	}
+
+	if h.Payload == nil {
+		return errors.New("payload type is required")
+	}
+
+	if h.Handle == nil {
+		return errors.New("handle func is required")
+	}
+
+	if h.Concurrency <= 0 {
+		return fmt.Errorf("concurrency must be greater than 0, got %d", h.Concurrency)
+	}
+
+	if h.Retry.MaxRetries < 0 {
+		return fmt.Errorf("max retries must not be negative, got %d", h.Retry.MaxRetries)
+	}
+
+	tiers := make(map[string]struct{}, len(h.Retry.Tiers))
+	for _, delay := range h.Retry.Tiers {
+		if delay <= 0 {
+			return fmt.Errorf("retry tier must be greater than 0, got %s", delay)
+		}
+
+		if _, ok := tiers[infra.RetryTierName(delay)]; ok {
+			return fmt.Errorf("duplicate retry tier: %s", delay)
+		}
+		tiers[infra.RetryTierName(delay)] = struct{}{}
+	}
+
+	return nil
+}
@@ -0,0 +1,166 @@
+package kafka
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"message-service-kata/pkg/cerror"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+func TestNewHandlerRegistry(t *testing.T) {
+	handler := func(topic string) TopicHandler {
+		return TopicHandler{
+			Topic:       topic,
+			Payload:     map[string]interface{}{},
+			Retry:       RetryPolicy{MaxRetries: 3, Tiers: []time.Duration{5 * time.Second, time.Minute}},
+			Concurrency: 1,
+			Handle: func(ctx context.Context, message *kafka.Message, payload interface{}) error {
+				return nil
+			},
+		}
+	}
+	with := func(topic string, change func(h *TopicHandler)) TopicHandler {
+		h := handler(topic)
+		change(&h)
+		return h
+	}
+
+	tests := []struct {
+		name       string
+		handlers   []TopicHandler
+		wantTopics []string
+		wantErr    string
+	}{
+		{
+			name:       "topics in alphabetical order",
+			handlers:   []TopicHandler{handler("message.publish"), handler("checkout"), handler("audit")},
+			wantTopics: []string{"audit", "checkout", "message.publish"},
+		},
+		{
+			name:       "no handler",
+			wantTopics: []string{},
+		},
+		{
+			name:     "duplicate topic",
+			handlers: []TopicHandler{handler("message.publish"), handler("message.publish")},
+			wantErr:  "topic message.publish: duplicate handler",
+		},
+		{
+			name:     "missing topic",
+			handlers: []TopicHandler{handler("")},
+			wantErr:  "topic is required",
+		},
+		{
+			name:     "missing payload",
+			handlers: []TopicHandler{with("message.publish", func(h *TopicHandler) { h.Payload = nil })},
+			wantErr:  "topic message.publish: payload type is required",
+		},
+		{
+			name:     "missing handle func",
+			handlers: []TopicHandler{with("message.publish", func(h *TopicHandler) { h.Handle = nil })},
+			wantErr:  "topic message.publish: handle func is required",
+		},
+		{
+			name:     "no worker",
+			handlers: []TopicHandler{with("message.publish", func(h *TopicHandler) { h.Concurrency = 0 })},
+			wantErr:  "topic message.publish: concurrency must be greater than 0, got 0",
+		},
+		{
+			name:     "negative max retries",
+			handlers: []TopicHandler{with("message.publish", func(h *TopicHandler) { h.Retry.MaxRetries = -1 })},
+			wantErr:  "topic message.publish: max retries must not be negative, got -1",
+		},
+		{
+			name:     "retry tier without delay",
+			handlers: []TopicHandler{with("message.publish", func(h *TopicHandler) { h.Retry.Tiers = []time.Duration{0} })},
+			wantErr:  "topic message.publish: retry tier must be greater than 0, got 0s",
+		},
+		{
+			name: "duplicate retry tier name",
+			handlers: []TopicHandler{with("message.publish", func(h *TopicHandler) {
+				h.Retry.Tiers = []time.Duration{time.Minute, 60 * time.Second}
+			})},
+			wantErr: "topic message.publish: duplicate retry tier: 1m0s",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry, err := NewHandlerRegistry(HandlerRegistryImpl{Handlers: tt.handlers})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("NewHandlerRegistry() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("NewHandlerRegistry() error = %v", err)
+			}
+
+			if got := registry.Topics(); !reflect.DeepEqual(got, tt.wantTopics) {
+				t.Fatalf("Topics() = %v, want %v", got, tt.wantTopics)
+			}
+
+			for _, topic := range tt.wantTopics {
+				if handler, ok := registry.Handler(topic); !ok || handler.Topic != topic {
+					t.Fatalf("Handler(%s) = %s, %v", topic, handler.Topic, ok)
+				}
+			}
+
+			if _, ok := registry.Handler("unknown"); ok {
+				t.Fatalf("Handler(unknown) is registered")
+			}
+		})
+	}
+}
+
+func TestTopicHandlerProcess(t *testing.T) {
+	type payload struct {
+		ID string `json:"id"`
+	}
+
+	tests := []struct {
+		name          string
+		value         string
+		wantPayload   payload
+		wantErr       bool
+		wantPermanent bool
+	}{
+		{name: "decoded payload", value: `{"id":"m1"}`, wantPayload: payload{ID: "m1"}},
+		{name: "invalid json", value: `{"id":`, wantErr: true, wantPermanent: true},
+		{name: "wrong type", value: `{"id":1}`, wantErr: true, wantPermanent: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got interface{}
+			handler := TopicHandler{
+				Topic:   "message.publish",
+				Payload: payload{},
+				Handle: func(ctx context.Context, message *kafka.Message, decoded interface{}) error {
+					got = decoded
+					return nil
+				},
+			}
+
+			err := handler.Process(context.Background(), &kafka.Message{Value: []byte(tt.value)})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Process() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if cerror.IsPermanent(err) != tt.wantPermanent {
+				t.Fatalf("Process() error class = %s, want permanent %v", cerror.ClassOf(err), tt.wantPermanent)
+			}
+
+			if !tt.wantErr && got != tt.wantPayload {
+				t.Fatalf("Process() payload = %#v, want %#v", got, tt.wantPayload)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"message-service-kata/internal/app/service"
+	"message-service-kata/pkg/cerror"
+	"message-service-kata/pkg/domain/entities"
+	"message-service-kata/pkg/domain/response"
+	"message-service-kata/pkg/validator"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/dig"
+)
+
+type (
+	// DeadLetterCtrl - controller interfacing for dead-letter queue
+	DeadLetterCtrl interface {
+		ListDeadLetter(c echo.Context) error
+		GetDeadLetter(c echo.Context) error
+	}
+
+	// DeadLetterCtrlImpl - Implement service / usecase in dead-letter queue controller
+	DeadLetterCtrlImpl struct {
+		dig.In
+		DeadLetterSvc service.DeadLetterSvc
+	}
+)
+
+// NewDeadLetterCtrl - dead-letter queue controller instance
+func NewDeadLetterCtrl(impl DeadLetterCtrlImpl) DeadLetterCtrl {
+	return &impl
+}
+
+// ListDeadLetter handler to list dead-letter messages of a topic
+func (r *DeadLetterCtrlImpl) ListDeadLetter(c echo.Context) error {
+	var (
+		req entities.ListDeadLetterRequest
+		ctx = c.Request().Context()
+	)
+
+	err := c.Bind(&req)
+	if err != nil {
+		return response.ErrUnprocessableEntity.WithInternal(err)
+	}
+
+	err = validator.Validate(req)
+	if err != nil {
+		return response.ErrBadRequest.WithInternal(err)
+	}
+
+	messages, err := r.DeadLetterSvc.ListDeadLetters(ctx, &req)
+	if err != nil {
+		return response.ErrInternalServerError.WithInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, response.HTTPResponse{
+		Status:  http.StatusOK,
+		Message: response.DefaultMessage,
+		Data:    messages,
+	})
+}
+
+// GetDeadLetter handler to inspect dead-letter message of a topic
+func (r *DeadLetterCtrlImpl) GetDeadLetter(c echo.Context) error {
+	var (
+		req entities.GetDeadLetterRequest
+		ctx = c.Request().Context()
+	)
+
+	err := c.Bind(&req)
+	if err != nil {
+		return response.ErrUnprocessableEntity.WithInternal(err)
+	}
+
+	err = validator.Validate(req)
+	if err != nil {
+		return response.ErrBadRequest.WithInternal(err)
+	}
+
+	message, err := r.DeadLetterSvc.GetDeadLetter(ctx, &req)
+	if errors.Is(err, cerror.ErrDeadLetterNotFound) {
+		return response.ErrNotFound.WithInternal(err)
+	}
+	if err != nil {
+		return response.ErrInternalServerError.WithInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, response.HTTPResponse{
+		Status:  http.StatusOK,
+		Message: response.DefaultMessage,
+		Data:    message,
+	})
+}
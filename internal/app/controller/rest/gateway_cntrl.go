@@ -0,0 +1,279 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"message-service-kata/internal/app/infra"
+	"message-service-kata/internal/app/service"
+	"message-service-kata/pkg/cerror"
+	"message-service-kata/pkg/domain/entities"
+	"message-service-kata/pkg/domain/response"
+	"message-service-kata/pkg/validator"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+	"go.uber.org/dig"
+)
+
+// gatewayWriteTimeout is the longest wait to write a frame to a websocket connection
+const gatewayWriteTimeout = 10 * time.Second
+
+// errGatewayFull error when websocket connection limit is reached or the gateway is shutting down
+var errGatewayFull = errors.New("websocket gateway does not accept new connection")
+
+type (
+	// GatewayCtrl - controller interfacing for websocket chat Gateway
+	GatewayCtrl interface {
+		Connect(c echo.Context) error
+		Shutdown(ctx context.Context)
+	}
+
+	// GatewayCtrlImpl - Implement service / usecase in websocket chat Gateway controller
+	GatewayCtrlImpl struct {
+		dig.In `ignore-unexported:"true"`
+
+		ChatSvc service.ChatSvc
+		AppCfg  *infra.AppCfg
+
+		upgrader websocket.Upgrader
+		conns    *gatewayConns
+	}
+
+	// gatewayConns track open websocket connections of the gateway
+	gatewayConns struct {
+		mu       sync.Mutex
+		reserved int
+		conns    map[*websocket.Conn]struct{}
+		closing  bool
+		wg       sync.WaitGroup
+	}
+)
+
+// NewGatewayCtrl - websocket chat Gateway controller instance
+func NewGatewayCtrl(impl GatewayCtrlImpl) GatewayCtrl {
+	impl.upgrader = websocket.Upgrader{
+		// Origin is not restricted, same as the CORS policy of the rest api
+		CheckOrigin: func(r *http.Request) bool { return true },
+	}
+	impl.conns = &gatewayConns{conns: make(map[*websocket.Conn]struct{})}
+
+	return &impl
+}
+
+// Connect handler to upgrade request to websocket chat session. Every text frame is a chat request
+// which is acknowledged with the published message ID, its reply is sent back on the same connection.
+func (r *GatewayCtrlImpl) Connect(c echo.Context) error {
+	if !r.conns.reserve(r.AppCfg.WSMaxConnections) {
+		return response.ErrServiceUnavailable.WithInternal(errGatewayFull)
+	}
+
+	ws, err := r.upgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		// Upgrader already replied with the error
+		r.conns.release(nil)
+		log.Error().Msgf("[GatewayCtrl][Connect] error while Upgrade : %v", err)
+		return nil
+	}
+	r.conns.track(ws)
+	defer r.conns.release(ws)
+
+	sessionID, replies, closeSession := r.ChatSvc.OpenSession()
+	defer closeSession()
+
+	log.Info().Msgf("[GatewayCtrl][Connect] session %s connected from %s", sessionID, c.RealIP())
+
+	frames := make(chan entities.ChatFrame, r.AppCfg.WSSendBuffer)
+	readerDone := make(chan struct{})
+	writerDone := make(chan struct{})
+
+	go func() {
+		defer close(writerDone)
+		r.writeFrames(ws, frames, replies, readerDone)
+	}()
+
+	r.readFrames(c.Request().Context(), ws, sessionID, frames, writerDone)
+	close(readerDone)
+	<-writerDone
+
+	log.Info().Msgf("[GatewayCtrl][Connect] session %s disconnected", sessionID)
+
+	return nil
+}
+
+// Shutdown close every connection with going away status and wait for them to end until ctx is done
+func (r *GatewayCtrlImpl) Shutdown(ctx context.Context) {
+	r.conns.mu.Lock()
+	r.conns.closing = true
+	for ws := range r.conns.conns {
+		err := ws.WriteControl(
+			websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"),
+			time.Now().Add(gatewayWriteTimeout),
+		)
+		if err != nil {
+			_ = ws.Close()
+		}
+	}
+	r.conns.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.conns.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		r.conns.mu.Lock()
+		for ws := range r.conns.conns {
+			_ = ws.Close()
+		}
+		r.conns.mu.Unlock()
+	}
+}
+
+// readFrames publish chat requests of the connection until it is closed
+func (r *GatewayCtrlImpl) readFrames(
+	ctx context.Context, ws *websocket.Conn, sessionID string, frames chan<- entities.ChatFrame, writerDone <-chan struct{},
+) {
+	ws.SetReadLimit(r.AppCfg.WSMaxMessageSize)
+	_ = ws.SetReadDeadline(time.Now().Add(r.AppCfg.WSPongTimeout))
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(r.AppCfg.WSPongTimeout))
+	})
+
+	for {
+		_, byt, err := ws.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Error().Msgf("[GatewayCtrl][readFrames] session %s error while ReadMessage : %v", sessionID, err)
+			}
+			return
+		}
+		_ = ws.SetReadDeadline(time.Now().Add(r.AppCfg.WSPongTimeout))
+
+		frame := r.send(ctx, sessionID, byt)
+
+		select {
+		case frames <- frame:
+		case <-writerDone:
+			return
+		}
+	}
+}
+
+// send publish a chat request frame and return the frame acknowledging it
+func (r *GatewayCtrlImpl) send(ctx context.Context, sessionID string, byt []byte) entities.ChatFrame {
+	var req entities.ChatRequest
+
+	err := json.Unmarshal(byt, &req)
+	if err != nil {
+		return entities.ChatFrame{Type: entities.ChatFrameError, Error: fmt.Sprintf("invalid chat request: %v", err)}
+	}
+
+	err = validator.Validate(req)
+	if err != nil {
+		return entities.ChatFrame{Type: entities.ChatFrameError, Error: err.Error()}
+	}
+
+	messageID, err := r.ChatSvc.Send(ctx, sessionID, &req)
+	if errors.Is(err, cerror.ErrConversationOwner) {
+		return entities.ChatFrame{Type: entities.ChatFrameError, Error: err.Error()}
+	}
+	if err != nil {
+		log.Error().Msgf("[GatewayCtrl][send] session %s error while Send : %v", sessionID, err)
+		return entities.ChatFrame{Type: entities.ChatFrameError, Error: cerror.ErrPublishMessage.Error()}
+	}
+
+	return entities.ChatFrame{Type: entities.ChatFrameAck, ID: messageID}
+}
+
+// writeFrames is the only writer of the connection, it sends acknowledgements, replies and keepalive pings
+// until the reader is done or writing fails
+func (r *GatewayCtrlImpl) writeFrames(
+	ws *websocket.Conn, frames <-chan entities.ChatFrame, replies <-chan entities.MessageReplyEvent, readerDone <-chan struct{},
+) {
+	ping := time.NewTicker(r.AppCfg.WSPingInterval)
+	defer ping.Stop()
+
+	// Unblock the reader when the connection is broken
+	defer ws.Close()
+
+	for {
+		var err error
+
+		select {
+		case <-readerDone:
+			_ = ws.WriteControl(
+				websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+				time.Now().Add(gatewayWriteTimeout),
+			)
+			return
+		case frame := <-frames:
+			err = r.writeFrame(ws, frame)
+		case reply := <-replies:
+			err = r.writeFrame(ws, entities.ChatFrame{Type: entities.ChatFrameReply, ID: reply.CorrelationID, Reply: &reply})
+		case <-ping.C:
+			err = ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(gatewayWriteTimeout))
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// writeFrame write frame as json text message
+func (r *GatewayCtrlImpl) writeFrame(ws *websocket.Conn, frame entities.ChatFrame) error {
+	err := ws.SetWriteDeadline(time.Now().Add(gatewayWriteTimeout))
+	if err != nil {
+		return err
+	}
+
+	return ws.WriteJSON(frame)
+}
+
+// reserve take a connection slot, it fails when the limit is reached or the gateway is shutting down
+func (g *gatewayConns) reserve(limit int) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.closing || g.reserved >= limit {
+		return false
+	}
+
+	g.reserved++
+	g.wg.Add(1)
+
+	return true
+}
+
+// track register upgraded connection so it can be closed on shutdown
+func (g *gatewayConns) track(ws *websocket.Conn) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.conns[ws] = struct{}{}
+}
+
+// release free connection slot, ws is nil when the upgrade failed
+func (g *gatewayConns) release(ws *websocket.Conn) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if ws != nil {
+		delete(g.conns, ws)
+	}
+
+	g.reserved--
+	g.wg.Done()
+}
@@ -1,10 +1,18 @@
 package controller
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
+	"message-service-kata/internal/app/infra"
 	"message-service-kata/internal/app/service"
+	"message-service-kata/pkg/cerror"
 	"message-service-kata/pkg/domain/entities"
 	"message-service-kata/pkg/domain/response"
 	"message-service-kata/pkg/validator"
@@ -17,13 +25,23 @@ type (
 	// MessageCtrl - controller interfacing for Message
 	MessageCtrl interface {
 		PostMessage(c echo.Context) error
+		Chat(c echo.Context) error
+		StreamMessage(c echo.Context) error
+		ListMessage(c echo.Context) error
+		GetMessage(c echo.Context) error
+		GetBatch(c echo.Context) error
+		GetConversation(c echo.Context) error
 		Health(c echo.Context) error
 	}
 
 	// MessageCtrlImpl - Implement service / usecase in Message controller
 	MessageCtrlImpl struct {
 		dig.In
-		MessageSvc service.MessageSvc
+		MessageSvc  service.MessageSvc
+		ResponseSvc service.ResponseSvc
+		ChatSvc     service.ChatSvc
+		StreamSvc   service.StreamSvc
+		AppCfg      *infra.AppCfg
 	}
 )
 
@@ -49,14 +67,293 @@ func (r *MessageCtrlImpl) PostMessage(c echo.Context) error {
 		return response.ErrBadRequest.WithInternal(err)
 	}
 
-	err = r.MessageSvc.PostMessage(ctx, &req)
+	if req.Qty > r.AppCfg.MaxQty {
+		return response.ErrBadRequest.WithInternal(fmt.Errorf("qty must not exceed %d", r.AppCfg.MaxQty))
+	}
+
+	if requested := req.Requested(); requested > r.AppCfg.MaxMessages {
+		return response.ErrBadRequest.WithInternal(
+			fmt.Errorf("qty times messages must not exceed %d, got %d", r.AppCfg.MaxMessages, requested),
+		)
+	}
+
+	batch, err := r.MessageSvc.PostMessage(ctx, &req)
+	if errors.Is(err, cerror.ErrConversationOwner) {
+		return response.ErrForbidden.WithInternal(err)
+	}
+	if errors.Is(err, cerror.ErrPublishMessage) {
+		return response.ErrServiceUnavailable.WithInternal(err)
+	}
 	if err != nil {
 		return response.ErrInternalServerError.WithInternal(err)
 	}
 
+	// Some messages failed to be published under best effort policy
+	if len(batch.Failures) > 0 {
+		return c.JSON(http.StatusMultiStatus, response.HTTPResponse{
+			Status:  http.StatusMultiStatus,
+			Message: response.ResponseMessageMultiStatus,
+			Data:    batch,
+		})
+	}
+
 	return c.JSON(http.StatusOK, response.HTTPResponse{
 		Status:  http.StatusOK,
 		Message: response.DefaultMessage,
+		Data:    batch,
+	})
+}
+
+// Chat handler to send message and wait for the chatbot reply
+func (r *MessageCtrlImpl) Chat(c echo.Context) error {
+	var (
+		req entities.ChatRequest
+		ctx = c.Request().Context()
+	)
+
+	err := c.Bind(&req)
+	if err != nil {
+		return response.ErrUnprocessableEntity.WithInternal(err)
+	}
+
+	err = validator.Validate(req)
+	if err != nil {
+		return response.ErrBadRequest.WithInternal(err)
+	}
+
+	reply, err := r.ChatSvc.Chat(ctx, &req)
+	if errors.Is(err, cerror.ErrConversationOwner) {
+		return response.ErrForbidden.WithInternal(err)
+	}
+	if errors.Is(err, cerror.ErrPublishMessage) || errors.Is(err, cerror.ErrReplyTimeout) ||
+		errors.Is(err, cerror.ErrReplyDisabled) {
+		return response.ErrServiceUnavailable.WithInternal(err)
+	}
+	if err != nil {
+		return response.ErrInternalServerError.WithInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, response.HTTPResponse{
+		Status:  http.StatusOK,
+		Message: response.DefaultMessage,
+		Data:    reply,
+	})
+}
+
+// StreamMessage handler to stream processed messages as server-sent events until the client leaves. A stream which
+// falls behind is ended, the client reconnects with Last-Event-ID to continue where it stopped.
+func (r *MessageCtrlImpl) StreamMessage(c echo.Context) error {
+	var req entities.StreamMessageRequest
+
+	err := c.Bind(&req)
+	if err != nil {
+		return response.ErrUnprocessableEntity.WithInternal(err)
+	}
+
+	if lastEventID := c.Request().Header.Get("Last-Event-ID"); lastEventID != "" {
+		req.LastEventID, err = strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil {
+			return response.ErrBadRequest.WithInternal(fmt.Errorf("invalid Last-Event-ID: %w", err))
+		}
+	}
+
+	err = validator.Validate(req)
+	if err != nil {
+		return response.ErrBadRequest.WithInternal(err)
+	}
+
+	ctx, cancel := context.WithCancel(c.Request().Context())
+	defer cancel()
+
+	messages, err := r.StreamSvc.Subscribe(ctx, &req)
+	if errors.Is(err, cerror.ErrReplyDisabled) {
+		return response.ErrServiceUnavailable.WithInternal(err)
+	}
+	if err != nil {
+		return response.ErrInternalServerError.WithInternal(err)
+	}
+
+	res := c.Response()
+
+	// The stream lives until the client leaves, not until the write timeout of the server
+	err = http.NewResponseController(res).SetWriteDeadline(time.Time{})
+	if err != nil {
+		return response.ErrInternalServerError.WithInternal(err)
+	}
+
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+
+	// Ask client to reconnect right away when the stream is ended
+	_, err = fmt.Fprint(res, "retry: 1000\n\n")
+	if err != nil {
+		return nil
+	}
+	res.Flush()
+
+	var heartbeat <-chan time.Time
+	if r.AppCfg.StreamHeartbeatInterval > 0 {
+		ticker := time.NewTicker(r.AppCfg.StreamHeartbeatInterval)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-heartbeat:
+			_, err = fmt.Fprint(res, ": heartbeat\n\n")
+		case message, ok := <-messages:
+			if !ok {
+				return nil
+			}
+
+			byt, errs := json.Marshal(message)
+			if errs != nil {
+				return nil
+			}
+
+			_, err = fmt.Fprintf(res, "id: %d\nevent: message\ndata: %s\n\n", message.ID, byt)
+		}
+
+		// Client is gone
+		if err != nil {
+			return nil
+		}
+		res.Flush()
+	}
+}
+
+// ListMessage handler to list consumed messages
+func (r *MessageCtrlImpl) ListMessage(c echo.Context) error {
+	var (
+		req entities.ListMessageRequest
+		ctx = c.Request().Context()
+	)
+
+	err := c.Bind(&req)
+	if err != nil {
+		return response.ErrUnprocessableEntity.WithInternal(err)
+	}
+
+	err = validator.Validate(req)
+	if err != nil {
+		return response.ErrBadRequest.WithInternal(err)
+	}
+
+	messages, nextCursor, err := r.MessageSvc.GetMessages(ctx, &req)
+	if err != nil {
+		return response.ErrInternalServerError.WithInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, response.HTTPResponse{
+		Status:  http.StatusOK,
+		Message: response.DefaultMessage,
+		Data:    messages,
+		Meta: response.CursorMeta{
+			Limit:      req.Limit,
+			NextCursor: nextCursor,
+		},
+	})
+}
+
+// GetMessage handler to get consumed message by id
+func (r *MessageCtrlImpl) GetMessage(c echo.Context) error {
+	var (
+		req entities.GetMessageRequest
+		ctx = c.Request().Context()
+	)
+
+	err := c.Bind(&req)
+	if err != nil {
+		return response.ErrUnprocessableEntity.WithInternal(err)
+	}
+
+	err = validator.Validate(req)
+	if err != nil {
+		return response.ErrBadRequest.WithInternal(err)
+	}
+
+	message, err := r.MessageSvc.GetMessage(ctx, req.ID)
+	if errors.Is(err, cerror.ErrNoRowsMessage) {
+		return response.ErrNotFound.WithInternal(err)
+	}
+	if err != nil {
+		return response.ErrInternalServerError.WithInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, response.HTTPResponse{
+		Status:  http.StatusOK,
+		Message: response.DefaultMessage,
+		Data:    message,
+	})
+}
+
+// GetBatch handler to get message batch progress by id
+func (r *MessageCtrlImpl) GetBatch(c echo.Context) error {
+	var (
+		req entities.GetBatchRequest
+		ctx = c.Request().Context()
+	)
+
+	err := c.Bind(&req)
+	if err != nil {
+		return response.ErrUnprocessableEntity.WithInternal(err)
+	}
+
+	err = validator.Validate(req)
+	if err != nil {
+		return response.ErrBadRequest.WithInternal(err)
+	}
+
+	batch, err := r.MessageSvc.GetBatch(ctx, req.ID)
+	if errors.Is(err, cerror.ErrNoRowsMessage) {
+		return response.ErrNotFound.WithInternal(err)
+	}
+	if err != nil {
+		return response.ErrInternalServerError.WithInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, response.HTTPResponse{
+		Status:  http.StatusOK,
+		Message: response.DefaultMessage,
+		Data:    batch,
+	})
+}
+
+// GetConversation handler to get conversation transcript by id
+func (r *MessageCtrlImpl) GetConversation(c echo.Context) error {
+	var (
+		req entities.GetConversationRequest
+		ctx = c.Request().Context()
+	)
+
+	err := c.Bind(&req)
+	if err != nil {
+		return response.ErrUnprocessableEntity.WithInternal(err)
+	}
+
+	err = validator.Validate(req)
+	if err != nil {
+		return response.ErrBadRequest.WithInternal(err)
+	}
+
+	conversation, err := r.MessageSvc.GetConversation(ctx, &req)
+	if errors.Is(err, cerror.ErrNoRowsMessage) {
+		return response.ErrNotFound.WithInternal(err)
+	}
+	if err != nil {
+		return response.ErrInternalServerError.WithInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, response.HTTPResponse{
+		Status:  http.StatusOK,
+		Message: response.DefaultMessage,
+		Data:    conversation,
 	})
 }
 
@@ -68,6 +365,8 @@ func (r *MessageCtrlImpl) Health(c echo.Context) error {
 		Environment   string `json:"environment"`
 		BuildCommitID string `json:"build_commit_id"`
 		BuidTimeStamp string `json:"build_timestamp"`
+
+		ResponseCatalogVersion string `json:"response_catalog_version"`
 	}
 
 	response := resp{
@@ -76,6 +375,8 @@ func (r *MessageCtrlImpl) Health(c echo.Context) error {
 		Environment:   os.Getenv("APP_BUILD_ENV"),
 		BuildCommitID: os.Getenv("APP_BUILD_COMMIT_ID"),
 		BuidTimeStamp: os.Getenv("APP_BUILD_TIMESTAMP"),
+
+		ResponseCatalogVersion: r.ResponseSvc.CatalogVersion(),
 	}
 
 	return c.JSON(http.StatusOK, response)
@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"message-service-kata/internal/app/service"
+	"message-service-kata/pkg/cerror"
+	"message-service-kata/pkg/domain/entities"
+	"message-service-kata/pkg/domain/response"
+	"message-service-kata/pkg/validator"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/dig"
+)
+
+type (
+	// ResponseCtrl - controller interfacing for chatbot Response
+	ResponseCtrl interface {
+		ListResponse(c echo.Context) error
+		CreateResponse(c echo.Context) error
+		UpdateResponse(c echo.Context) error
+		DeleteResponse(c echo.Context) error
+	}
+
+	// ResponseCtrlImpl - Implement service / usecase in chatbot Response controller
+	ResponseCtrlImpl struct {
+		dig.In
+		ResponseSvc service.ResponseSvc
+	}
+)
+
+// NewResponseCtrl - chatbot Response controller instance
+func NewResponseCtrl(impl ResponseCtrlImpl) ResponseCtrl {
+	return &impl
+}
+
+// ListResponse handler to list chatbot responses
+func (r *ResponseCtrlImpl) ListResponse(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	responses, err := r.ResponseSvc.ListResponses(ctx)
+	if err != nil {
+		return response.ErrInternalServerError.WithInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, response.HTTPResponse{
+		Status:  http.StatusOK,
+		Message: response.DefaultMessage,
+		Data:    responses,
+	})
+}
+
+// CreateResponse handler to create chatbot response
+func (r *ResponseCtrlImpl) CreateResponse(c echo.Context) error {
+	var (
+		req entities.UpsertResponseRequest
+		ctx = c.Request().Context()
+	)
+
+	err := c.Bind(&req)
+	if err != nil {
+		return response.ErrUnprocessableEntity.WithInternal(err)
+	}
+
+	err = validator.Validate(req)
+	if err != nil {
+		return response.ErrBadRequest.WithInternal(err)
+	}
+
+	chatbotResponse, err := r.ResponseSvc.CreateResponse(ctx, &req)
+	if errors.Is(err, cerror.ErrInvalidResponseRule) {
+		return response.ErrBadRequest.WithInternal(err)
+	}
+	if err != nil {
+		return response.ErrInternalServerError.WithInternal(err)
+	}
+
+	return c.JSON(http.StatusCreated, response.HTTPResponse{
+		Status:  http.StatusCreated,
+		Message: response.DefaultMessage,
+		Data:    chatbotResponse,
+	})
+}
+
+// UpdateResponse handler to update chatbot response
+func (r *ResponseCtrlImpl) UpdateResponse(c echo.Context) error {
+	var (
+		req entities.UpsertResponseRequest
+		ctx = c.Request().Context()
+	)
+
+	err := c.Bind(&req)
+	if err != nil {
+		return response.ErrUnprocessableEntity.WithInternal(err)
+	}
+
+	err = validator.Validate(req)
+	if err != nil {
+		return response.ErrBadRequest.WithInternal(err)
+	}
+
+	if req.ID <= 0 {
+		return response.ErrBadRequest.WithInternal(errors.New("invalid chatbot response id"))
+	}
+
+	chatbotResponse, err := r.ResponseSvc.UpdateResponse(ctx, &req)
+	switch {
+	case errors.Is(err, cerror.ErrInvalidResponseRule):
+		return response.ErrBadRequest.WithInternal(err)
+	case errors.Is(err, cerror.ErrNoRowsMessage):
+		return response.ErrNotFound.WithInternal(err)
+	case err != nil:
+		return response.ErrInternalServerError.WithInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, response.HTTPResponse{
+		Status:  http.StatusOK,
+		Message: response.DefaultMessage,
+		Data:    chatbotResponse,
+	})
+}
+
+// DeleteResponse handler to delete chatbot response
+func (r *ResponseCtrlImpl) DeleteResponse(c echo.Context) error {
+	var (
+		req entities.DeleteResponseRequest
+		ctx = c.Request().Context()
+	)
+
+	err := c.Bind(&req)
+	if err != nil {
+		return response.ErrUnprocessableEntity.WithInternal(err)
+	}
+
+	err = validator.Validate(req)
+	if err != nil {
+		return response.ErrBadRequest.WithInternal(err)
+	}
+
+	err = r.ResponseSvc.DeleteResponse(ctx, req.ID)
+	if errors.Is(err, cerror.ErrNoRowsMessage) {
+		return response.ErrNotFound.WithInternal(err)
+	}
+	if err != nil {
+		return response.ErrInternalServerError.WithInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, response.HTTPResponse{
+		Status:  http.StatusOK,
+		Message: response.DefaultMessage,
+	})
+}
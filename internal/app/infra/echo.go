@@ -1,6 +1,7 @@
 package infra
 
 import (
+	"fmt"
 	"time"
 
 	"message-service-kata/pkg/domain/response"
@@ -20,9 +21,45 @@ type (
 		BuildEnv       string        `envconfig:"BUILD_ENV" default:"local"`
 		BuildCommitID  string        `envconfig:"BUILD_COMMIT_ID" default:"local"`
 		BuildTimestamp string        `envconfig:"BUILD_TIMESTAMP" default:"local"`
+		MaxQty         int64         `envconfig:"MAX_QTY" default:"1000"`
+		MaxMessages    int64         `envconfig:"MAX_MESSAGES" default:"10000"`
+		ChatTimeout    time.Duration `envconfig:"CHAT_TIMEOUT" default:"5s"`
+
+		StreamHeartbeatInterval time.Duration `envconfig:"STREAM_HEARTBEAT_INTERVAL" default:"15s"`
+
+		WSMaxConnections int           `envconfig:"WS_MAX_CONNECTIONS" default:"1000"`
+		WSMaxMessageSize int64         `envconfig:"WS_MAX_MESSAGE_SIZE" default:"16384"`
+		WSPingInterval   time.Duration `envconfig:"WS_PING_INTERVAL" default:"30s"`
+		WSPongTimeout    time.Duration `envconfig:"WS_PONG_TIMEOUT" default:"60s"`
+		WSSendBuffer     int           `envconfig:"WS_SEND_BUFFER" default:"64"`
+
+		ResponsesRefreshInterval time.Duration `envconfig:"RESPONSES_REFRESH_INTERVAL" default:"1m"`
+		ResponsesFile            string        `envconfig:"RESPONSES_FILE"`
+		ResponsesWatchInterval   time.Duration `envconfig:"RESPONSES_WATCH_INTERVAL" default:"5s"`
+		ResponseMatchThreshold   float64       `envconfig:"RESPONSE_MATCH_THRESHOLD" default:"0.8"`
+
+		DialogsFile string        `envconfig:"DIALOGS_FILE"`
+		DialogTTL   time.Duration `envconfig:"DIALOG_TTL" default:"10m"`
 	}
 )
 
+// Validate validating application config values which can not be expressed by envconfig tags
+func (cfg *AppCfg) Validate() error {
+	if cfg.WSPingInterval <= 0 {
+		return fmt.Errorf("websocket ping interval must be greater than 0, got %s", cfg.WSPingInterval)
+	}
+
+	if cfg.WSPongTimeout <= cfg.WSPingInterval {
+		return fmt.Errorf("websocket pong timeout must be greater than ping interval %s, got %s", cfg.WSPingInterval, cfg.WSPongTimeout)
+	}
+
+	if cfg.WSSendBuffer <= 0 {
+		return fmt.Errorf("websocket send buffer must be greater than 0, got %d", cfg.WSSendBuffer)
+	}
+
+	return nil
+}
+
 // NewEcho used to create echo instance
 func NewEcho(cfg *AppCfg) *echo.Echo {
 	e := echo.New()
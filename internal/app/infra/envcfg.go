@@ -11,12 +11,18 @@ const (
 	ServiceConsumerKafka = "consumer"
 	// ServiceRestAPI variable service rest api
 	ServiceRestAPI = "rest"
+	// ServiceDLQReplay variable service replaying dead-letter queue
+	ServiceDLQReplay = "dlq-replay"
+	// ServiceOutboxRelay variable service publishing outbox messages
+	ServiceOutboxRelay = "outbox-relay"
 )
 
 // AvailableServices initiate available server on this service
 var AvailableServices = []string{
 	ServiceRestAPI,
 	ServiceConsumerKafka,
+	ServiceDLQReplay,
+	ServiceOutboxRelay,
 }
 
 // LoadPgDatabaseCfg loading postgres database config using envconfig library
@@ -37,6 +43,11 @@ func LoadAppCfg() (*AppCfg, error) {
 	if err := envconfig.Process(prefix, &cfg); err != nil {
 		return nil, fmt.Errorf("%s: %w", prefix, err)
 	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", prefix, err)
+	}
+
 	return &cfg, nil
 }
 
@@ -48,5 +59,9 @@ func LoadKafkaCfg() (*KafkaCfg, error) {
 		return nil, fmt.Errorf("%s: %w", prefix, err)
 	}
 
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", prefix, err)
+	}
+
 	return &cfg, nil
 }
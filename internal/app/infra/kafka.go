@@ -1,20 +1,160 @@
 package infra
 
 import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 )
 
+const (
+	// PublishPolicyFailFast stop publishing the remaining messages of a request on the first failure
+	PublishPolicyFailFast = "fail_fast"
+	// PublishPolicyBestEffort publish every message of a request and report the failed ones
+	PublishPolicyBestEffort = "best_effort"
+)
+
 type (
 	// KafkaCfg used to load kafka config from .env
 	KafkaCfg struct {
-		BrokerAddress      string `envconfig:"BROKER_ADDR" required:"true" default:"127.0.0.1:9092"`
-		GroupID            string `envconfig:"GROUP_ID" required:"true" default:"message-consumer-group"`
-		MaxConsumerRetries int    `envconfig:"MAX_CONSUMER_RETRIES" required:"true" default:"3"`
+		BrokerAddress      string        `envconfig:"BROKER_ADDR" required:"true" default:"127.0.0.1:9092"`
+		GroupID            string        `envconfig:"GROUP_ID" required:"true" default:"message-consumer-group"`
+		MaxConsumerRetries int           `envconfig:"MAX_CONSUMER_RETRIES" required:"true" default:"3"`
+		ConsumerWorkers    int           `envconfig:"CONSUMER_WORKERS" required:"true" default:"1"`
+		ConsumerBuffer     int           `envconfig:"CONSUMER_BUFFER" required:"true" default:"100"`
+		PublishPolicy      string        `envconfig:"PUBLISH_POLICY" required:"true" default:"best_effort"`
+		PublishTimeout     time.Duration `envconfig:"PUBLISH_TIMEOUT" required:"true" default:"5s"`
+		PublishConcurrency int           `envconfig:"PUBLISH_CONCURRENCY" required:"true" default:"50"`
+		PublishAsync       bool          `envconfig:"PUBLISH_ASYNC" default:"false"`
+		PublishMaxInFlight int           `envconfig:"PUBLISH_MAX_IN_FLIGHT" required:"true" default:"10000"`
+		ReplyTopic         string        `envconfig:"REPLY_TOPIC" default:"message.reply"`
+
+		RetryBackoffBase time.Duration `envconfig:"RETRY_BACKOFF_BASE" required:"true" default:"100ms"`
+		RetryBackoffMax  time.Duration `envconfig:"RETRY_BACKOFF_MAX" required:"true" default:"2s"`
+		// RetryTiers are the delays of the retry topics a failed message goes through before the dead-letter queue
+		RetryTiers []time.Duration `envconfig:"RETRY_TIERS" default:"5s,1m"`
+		// DeadLetterScanLimit is the number of dead-letter messages one list request reads at most
+		DeadLetterScanLimit int `envconfig:"DLQ_SCAN_LIMIT" required:"true" default:"10000"`
+
+		// PublishOutbox stores posted messages in the outbox table within the request transaction instead of
+		// publishing them, the outbox-relay service publishes them in batches of OutboxBatchSize
+		PublishOutbox      bool          `envconfig:"PUBLISH_OUTBOX" default:"false"`
+		OutboxPollInterval time.Duration `envconfig:"OUTBOX_POLL_INTERVAL" required:"true" default:"1s"`
+		OutboxBatchSize    int           `envconfig:"OUTBOX_BATCH_SIZE" required:"true" default:"500"`
+		// OutboxMaxAttempts is the number of failed publish attempts after which an outbox message is parked as failed,
+		// a failed message is published again after OutboxRetryBackoff doubled on every attempt up to OutboxRetryBackoffMax
+		OutboxMaxAttempts     int           `envconfig:"OUTBOX_MAX_ATTEMPTS" required:"true" default:"10"`
+		OutboxRetryBackoff    time.Duration `envconfig:"OUTBOX_RETRY_BACKOFF" required:"true" default:"1s"`
+		OutboxRetryBackoffMax time.Duration `envconfig:"OUTBOX_RETRY_BACKOFF_MAX" required:"true" default:"5m"`
+	}
+
+	// ReplyConsumer is kafka consumer of the reply topic used by the rest service, every instance
+	// has its own consumer group so each of them receives all replies
+	ReplyConsumer struct {
+		*kafka.Consumer
+		// StartedAt is when the instance started, its partitions are consumed from the replies produced since then
+		StartedAt time.Time
+	}
+
+	// InvalidationConsumer is kafka consumer of the chatbot response invalidation topic, every instance
+	// has its own consumer group so each of them refreshes its cached chatbot responses
+	InvalidationConsumer struct {
+		*kafka.Consumer
 	}
+
+	// DeadLetterReader is kafka consumer reading the dead-letter queues for the admin endpoints, it lives as long as
+	// the rest service. Partitions are assigned for every read and reads take turns since the consumer is shared.
+	DeadLetterReader struct {
+		*kafka.Consumer
+		sync.Mutex
+	}
+
+	// DeliveryHandler used to receive delivery report of message produced without delivery channel,
+	// set it as the message Opaque
+	DeliveryHandler func(err error)
 )
 
+// Validate validating kafka config values which can not be expressed by envconfig tags
+func (cfg *KafkaCfg) Validate() error {
+	switch cfg.PublishPolicy {
+	case PublishPolicyFailFast, PublishPolicyBestEffort:
+	default:
+		return fmt.Errorf("unknown publish policy: %s", cfg.PublishPolicy)
+	}
+
+	if cfg.PublishConcurrency <= 0 {
+		return fmt.Errorf("publish concurrency must be greater than 0, got %d", cfg.PublishConcurrency)
+	}
+
+	if cfg.ConsumerWorkers <= 0 {
+		return fmt.Errorf("consumer workers must be greater than 0, got %d", cfg.ConsumerWorkers)
+	}
+
+	if cfg.ConsumerBuffer <= 0 {
+		return fmt.Errorf("consumer buffer must be greater than 0, got %d", cfg.ConsumerBuffer)
+	}
+
+	if cfg.OutboxPollInterval <= 0 {
+		return fmt.Errorf("outbox poll interval must be greater than 0, got %s", cfg.OutboxPollInterval)
+	}
+
+	if cfg.OutboxBatchSize <= 0 {
+		return fmt.Errorf("outbox batch size must be greater than 0, got %d", cfg.OutboxBatchSize)
+	}
+
+	if cfg.OutboxMaxAttempts <= 0 {
+		return fmt.Errorf("outbox max attempts must be greater than 0, got %d", cfg.OutboxMaxAttempts)
+	}
+
+	if cfg.OutboxRetryBackoff <= 0 || cfg.OutboxRetryBackoffMax < cfg.OutboxRetryBackoff {
+		return fmt.Errorf("outbox retry backoff must be greater than 0 and not above its max %s, got %s", cfg.OutboxRetryBackoffMax, cfg.OutboxRetryBackoff)
+	}
+
+	if cfg.DeadLetterScanLimit <= 0 {
+		return fmt.Errorf("dead-letter scan limit must be greater than 0, got %d", cfg.DeadLetterScanLimit)
+	}
+
+	if cfg.PublishMaxInFlight <= 0 {
+		return fmt.Errorf("publish max in flight must be greater than 0, got %d", cfg.PublishMaxInFlight)
+	}
+
+	if cfg.RetryBackoffBase <= 0 || cfg.RetryBackoffMax < cfg.RetryBackoffBase {
+		return fmt.Errorf("retry backoff must be greater than 0 and not above its max %s, got %s", cfg.RetryBackoffMax, cfg.RetryBackoffBase)
+	}
+
+	tiers := make(map[string]struct{}, len(cfg.RetryTiers))
+	for _, delay := range cfg.RetryTiers {
+		if delay <= 0 {
+			return fmt.Errorf("retry tier must be greater than 0, got %s", delay)
+		}
+
+		if _, ok := tiers[RetryTierName(delay)]; ok {
+			return fmt.Errorf("duplicate retry tier: %s", delay)
+		}
+		tiers[RetryTierName(delay)] = struct{}{}
+	}
+
+	return nil
+}
+
+// RetryTierName return short name of retry tier delay used as retry topic suffix, e.g. 5s, 1m or 2h
+func RetryTierName(delay time.Duration) string {
+	switch {
+	case delay%time.Hour == 0:
+		return fmt.Sprintf("%dh", delay/time.Hour)
+	case delay%time.Minute == 0:
+		return fmt.Sprintf("%dm", delay/time.Minute)
+	case delay%time.Second == 0:
+		return fmt.Sprintf("%ds", delay/time.Second)
+	default:
+		return fmt.Sprintf("%dms", delay/time.Millisecond)
+	}
+}
+
 // NewConsumer used to connect  to Kafka consumer instance
 func NewConsumer(cfg *KafkaCfg) *kafka.Consumer {
 	log.Info().Msg(cfg.GroupID)
@@ -32,14 +172,92 @@ func NewConsumer(cfg *KafkaCfg) *kafka.Consumer {
 	return c
 }
 
+// NewReplyConsumer used to connect to Kafka consumer of the reply topic, only replies produced after
+// the instance started are consumed
+func NewReplyConsumer(cfg *KafkaCfg) *ReplyConsumer {
+	startedAt := time.Now()
+	groupID := fmt.Sprintf("%s-reply-%s", cfg.GroupID, uuid.NewString())
+	log.Info().Msg(groupID)
+
+	c, err := kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers":  cfg.BrokerAddress,
+		"group.id":           groupID,
+		"auto.offset.reset":  "latest",
+		"enable.auto.commit": false,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create reply consumer")
+	}
+
+	return &ReplyConsumer{Consumer: c, StartedAt: startedAt}
+}
+
+// NewInvalidationConsumer used to connect to Kafka consumer of the chatbot response invalidation topic, only
+// invalidations produced after the instance started are consumed
+func NewInvalidationConsumer(cfg *KafkaCfg) *InvalidationConsumer {
+	groupID := fmt.Sprintf("%s-invalidate-%s", cfg.GroupID, uuid.NewString())
+	log.Info().Msg(groupID)
+
+	c, err := kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers":  cfg.BrokerAddress,
+		"group.id":           groupID,
+		"auto.offset.reset":  "latest",
+		"enable.auto.commit": false,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create invalidation consumer")
+	}
+
+	return &InvalidationConsumer{Consumer: c}
+}
+
+// NewDeadLetterReader used to connect to Kafka consumer reading the dead-letter queues, it never commits offsets
+func NewDeadLetterReader(cfg *KafkaCfg) *DeadLetterReader {
+	c, err := kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers":  cfg.BrokerAddress,
+		"group.id":           fmt.Sprintf("%s-dlq-inspect", cfg.GroupID),
+		"auto.offset.reset":  "earliest",
+		"enable.auto.commit": false,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create dead-letter reader")
+	}
+
+	return &DeadLetterReader{Consumer: c}
+}
+
 // NewProducer used to connect  to Kafka producer instance
 func NewProducer(cfg *KafkaCfg) *kafka.Producer {
 	p, err := kafka.NewProducer(&kafka.ConfigMap{
 		"bootstrap.servers": cfg.BrokerAddress,
+		// report delivery failure instead of retrying for librdkafka default of 5 minutes when broker is down
+		"message.timeout.ms": int(cfg.PublishTimeout.Milliseconds()),
 	})
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to create producer")
 	}
 
+	go handleDeliveryReports(p)
+
 	return p
 }
+
+// handleDeliveryReports is the shared delivery report handler of the producer. Every message produced
+// without delivery channel is reported here and dispatched to the DeliveryHandler set as its Opaque.
+func handleDeliveryReports(p *kafka.Producer) {
+	for e := range p.Events() {
+		switch ev := e.(type) {
+		case *kafka.Message:
+			if handler, ok := ev.Opaque.(DeliveryHandler); ok {
+				handler(ev.TopicPartition.Error)
+				continue
+			}
+
+			if ev.TopicPartition.Error != nil {
+				log.Error().Any("topic", ev.TopicPartition).Any("error", ev.TopicPartition.Error).Msg("error delivery kafka message")
+			}
+		case kafka.Error:
+			log.Error().Any("error", ev).Msg("kafka producer error")
+		}
+	}
+}
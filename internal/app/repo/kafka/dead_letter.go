@@ -0,0 +1,465 @@
+package kafka
+
+//go:generate mockery --dir=$PROJECT_DIR/internal/app/repo/kafka  --name=DeadLetterRepository --filename=$GOFILE --output=$PROJECT_DIR/internal/generated/mock_kafka --outpkg=mock_kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"message-service-kata/internal/app/infra"
+	"message-service-kata/pkg/cerror"
+	"message-service-kata/pkg/ckafka"
+	"message-service-kata/pkg/domain/entities"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/rs/zerolog/log"
+	"go.uber.org/dig"
+)
+
+const (
+	// deadLetterTimeout is the longest wait for broker metadata and offsets of the dead-letter queue
+	deadLetterTimeout = 10 * time.Second
+	// deadLetterPollTimeout is the longest wait for a dead-letter message before checking progress again
+	deadLetterPollTimeout = time.Second
+)
+
+type (
+	// DeadLetterVisitor is called with every message read from the dead-letter queue, reading stops when
+	// it returns false or an error
+	DeadLetterVisitor func(msg entities.DeadLetterMessage) (next bool, err error)
+
+	// DeadLetterProcessor is called with every message consumed from the dead-letter queue. The message is committed
+	// when it returns advance, otherwise it is left uncommitted and its partition is not read any further. Consuming
+	// stops on error.
+	DeadLetterProcessor func(msg entities.DeadLetterMessage) (advance bool, err error)
+
+	// deadLetterRead tell readDeadLetters how to go on after a message is visited
+	deadLetterRead int
+
+	// DeadLetterRepositoryImpl implementing dead-letter queue reader and republisher. Reader is only provided
+	// to the rest service, which inspects the dead-letter queues.
+	DeadLetterRepositoryImpl struct {
+		dig.In
+		KafkaProduce *kafka.Producer
+		KafkaCfg     *infra.KafkaCfg
+		Reader       *infra.DeadLetterReader `optional:"true"`
+	}
+
+	// DeadLetterRepository interfacing dead-letter queue repository function
+	DeadLetterRepository interface {
+		Scan(ctx context.Context, topic string, visit DeadLetterVisitor) (err error)
+		Get(ctx context.Context, topic string, partition int32, offset int64) (msg entities.DeadLetterMessage, err error)
+		Consume(ctx context.Context, topic, groupID string, commit bool, process DeadLetterProcessor) (err error)
+		Republish(ctx context.Context, msg entities.DeadLetterMessage) (err error)
+	}
+)
+
+const (
+	// readNext read the next message
+	readNext deadLetterRead = iota
+	// readStopPartition read no further message of the partition of the visited message
+	readStopPartition
+	// readStop stop reading
+	readStop
+)
+
+// NewDeadLetterRepository Initiating dead-letter queue repository
+func NewDeadLetterRepository(impl DeadLetterRepositoryImpl) DeadLetterRepository {
+	return &impl
+}
+
+// Scan read the dead-letter queue of the original topic from its oldest message up to the messages stored when
+// the scan started, at most KafkaCfg.DeadLetterScanLimit messages are read. No offset is committed.
+func (ox *DeadLetterRepositoryImpl) Scan(ctx context.Context, topic string, visit DeadLetterVisitor) (err error) {
+	consumer, release, err := ox.reader()
+	if err != nil {
+		return fmt.Errorf("[repository][Scan] while taking reader : %w", err)
+	}
+	defer release()
+
+	scanned := 0
+	return readDeadLetters(ctx, consumer, ckafka.DeadLetterTopic(topic), func(msg entities.DeadLetterMessage) (deadLetterRead, error) {
+		next, err := visit(msg)
+		if err != nil || !next {
+			return readStop, err
+		}
+
+		scanned++
+		if scanned >= ox.KafkaCfg.DeadLetterScanLimit {
+			return readStop, nil
+		}
+
+		return readNext, nil
+	})
+}
+
+// Get read message of the dead-letter queue of the original topic at partition and offset
+func (ox *DeadLetterRepositoryImpl) Get(
+	ctx context.Context, topic string, partition int32, offset int64,
+) (msg entities.DeadLetterMessage, err error) {
+	consumer, release, err := ox.reader()
+	if err != nil {
+		return msg, fmt.Errorf("[repository][Get] while taking reader : %w", err)
+	}
+	defer release()
+
+	dlqTopic := ckafka.DeadLetterTopic(topic)
+
+	partitions, err := topicPartitions(consumer, dlqTopic)
+	if err != nil {
+		return msg, fmt.Errorf("[repository][Get] while reading metadata : %w", err)
+	}
+
+	if !containsPartition(partitions, partition) {
+		return msg, cerror.ErrDeadLetterNotFound
+	}
+
+	low, high, err := consumer.QueryWatermarkOffsets(dlqTopic, partition, int(deadLetterTimeout.Milliseconds()))
+	if err != nil {
+		return msg, fmt.Errorf("[repository][Get] while querying watermarks : %w", err)
+	}
+
+	if offset < low || offset >= high {
+		return msg, cerror.ErrDeadLetterNotFound
+	}
+
+	err = consumer.Assign([]kafka.TopicPartition{{Topic: &dlqTopic, Partition: partition, Offset: kafka.Offset(offset)}})
+	if err != nil {
+		return msg, fmt.Errorf("[repository][Get] while assigning partition : %w", err)
+	}
+
+	for {
+		if err = ctx.Err(); err != nil {
+			return msg, err
+		}
+
+		kafkaMsg, err := consumer.ReadMessage(deadLetterPollTimeout)
+		if isTimeout(err) {
+			continue
+		}
+		if err != nil {
+			return msg, fmt.Errorf("[repository][Get] while reading message : %w", err)
+		}
+
+		// Offset may be missing on compacted or transactional topics, the next message is read instead
+		if int64(kafkaMsg.TopicPartition.Offset) != offset {
+			return msg, cerror.ErrDeadLetterNotFound
+		}
+
+		return newDeadLetterMessage(kafkaMsg), nil
+	}
+}
+
+// Consume read the dead-letter queue of the original topic from the committed offsets of groupID up to the messages
+// stored when it started. The offset of a message is committed once process advances past it, unless commit is false.
+// A partition is not read past a message process does not advance past, so its committed offset never skips it.
+func (ox *DeadLetterRepositoryImpl) Consume(
+	ctx context.Context, topic, groupID string, commit bool, process DeadLetterProcessor,
+) (err error) {
+	consumer, err := ox.newConsumer(groupID)
+	if err != nil {
+		return fmt.Errorf("[repository][Consume] while creating consumer : %w", err)
+	}
+	defer consumer.Close()
+
+	dlqTopic := ckafka.DeadLetterTopic(topic)
+
+	return readDeadLetters(ctx, consumer, dlqTopic, func(msg entities.DeadLetterMessage) (deadLetterRead, error) {
+		advance, err := process(msg)
+		if err != nil {
+			return readStop, err
+		}
+
+		if !advance {
+			return readStopPartition, nil
+		}
+
+		if commit {
+			tp := kafka.TopicPartition{Topic: &dlqTopic, Partition: msg.Partition, Offset: kafka.Offset(msg.Offset + 1)}
+			_, err = consumer.CommitOffsets([]kafka.TopicPartition{tp})
+			if err != nil {
+				return readStop, fmt.Errorf("[repository][Consume] while committing offset : %w", err)
+			}
+		}
+
+		return readNext, nil
+	})
+}
+
+// Republish produce dead-letter message to its original topic without failure headers and wait for its delivery
+func (ox *DeadLetterRepositoryImpl) Republish(ctx context.Context, msg entities.DeadLetterMessage) (err error) {
+	topic := msg.OriginalTopic
+	if topic == "" {
+		topic = ckafka.DeadLetterOriginTopic(msg.Topic)
+	}
+
+	kafkaMsg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+		Value:          []byte(msg.Value),
+	}
+	if msg.Key != "" {
+		kafkaMsg.Key = []byte(msg.Key)
+	}
+	for key, value := range msg.Headers {
+		kafkaMsg.Headers = append(kafkaMsg.Headers, kafka.Header{Key: key, Value: []byte(value)})
+	}
+
+	deliverChan := make(chan kafka.Event, 1)
+	err = ox.KafkaProduce.Produce(kafkaMsg, deliverChan)
+	if err != nil {
+		// message is not enqueued, so no delivery report will be sent
+		return fmt.Errorf("[repository][Republish] while producing : %w", err)
+	}
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("[repository][Republish] while waiting delivery : %w", ctx.Err())
+	case kafkEvent := <-deliverChan:
+		delivered, ok := kafkEvent.(*kafka.Message)
+		if !ok {
+			return fmt.Errorf("[repository][Republish] unexpected delivery report of topic %s", topic)
+		}
+
+		return delivered.TopicPartition.Error
+	}
+}
+
+// reader take the shared dead-letter reader until release is called, its partitions are unassigned on release
+func (ox *DeadLetterRepositoryImpl) reader() (consumer *kafka.Consumer, release func(), err error) {
+	if ox.Reader == nil {
+		return nil, nil, errors.New("dead-letter reader is not provided")
+	}
+
+	ox.Reader.Lock()
+
+	return ox.Reader.Consumer, func() {
+		defer ox.Reader.Unlock()
+
+		if err := ox.Reader.Unassign(); err != nil {
+			log.Error().Msgf("[repository][DeadLetter] error while Unassign reader : %v", err)
+		}
+	}, nil
+}
+
+// newConsumer create consumer of the dead-letter queue, partitions are assigned manually and offsets are
+// committed explicitly
+func (ox *DeadLetterRepositoryImpl) newConsumer(groupID string) (*kafka.Consumer, error) {
+	return kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers":  ox.KafkaCfg.BrokerAddress,
+		"group.id":           groupID,
+		"auto.offset.reset":  "earliest",
+		"enable.auto.commit": false,
+	})
+}
+
+// readDeadLetters assign every partition of the dead-letter topic from the committed offset, or the oldest one,
+// and visit messages until the high watermarks captured before reading. Messages republished during the read
+// are stored past those watermarks, so a message failing again is not read twice.
+//
+//nolint:gocyclo
+func readDeadLetters(
+	ctx context.Context, consumer *kafka.Consumer, dlqTopic string, visit func(msg entities.DeadLetterMessage) (deadLetterRead, error),
+) error {
+	partitions, err := topicPartitions(consumer, dlqTopic)
+	if err != nil {
+		return fmt.Errorf("[repository][readDeadLetters] while reading metadata : %w", err)
+	}
+	if len(partitions) == 0 {
+		return nil
+	}
+
+	assigned := make([]kafka.TopicPartition, 0, len(partitions))
+	for _, partition := range partitions {
+		assigned = append(assigned, kafka.TopicPartition{Topic: &dlqTopic, Partition: partition})
+	}
+
+	committed, err := consumer.Committed(assigned, int(deadLetterTimeout.Milliseconds()))
+	if err != nil {
+		return fmt.Errorf("[repository][readDeadLetters] while reading committed offsets : %w", err)
+	}
+
+	// remaining hold the high watermark of the partitions which are not read up to it yet
+	remaining := make(map[int32]kafka.Offset, len(partitions))
+	assigned = assigned[:0]
+	for _, tp := range committed {
+		low, high, err := consumer.QueryWatermarkOffsets(dlqTopic, tp.Partition, int(deadLetterTimeout.Milliseconds()))
+		if err != nil {
+			return fmt.Errorf("[repository][readDeadLetters] while querying watermarks : %w", err)
+		}
+
+		start := tp.Offset
+		if start < kafka.Offset(low) {
+			start = kafka.Offset(low)
+		}
+
+		if start >= kafka.Offset(high) {
+			continue
+		}
+
+		remaining[tp.Partition] = kafka.Offset(high)
+		assigned = append(assigned, kafka.TopicPartition{Topic: &dlqTopic, Partition: tp.Partition, Offset: start})
+	}
+
+	if len(remaining) == 0 {
+		return nil
+	}
+
+	err = consumer.Assign(assigned)
+	if err != nil {
+		return fmt.Errorf("[repository][readDeadLetters] while assigning partitions : %w", err)
+	}
+
+	for len(remaining) > 0 {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
+		kafkaMsg, err := consumer.ReadMessage(deadLetterPollTimeout)
+		if isTimeout(err) {
+			// Offsets after the last message may never be delivered, e.g. transaction markers
+			dropReachedPartitions(consumer, assigned, remaining)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("[repository][readDeadLetters] while reading message : %w", err)
+		}
+
+		tp := kafkaMsg.TopicPartition
+		high, ok := remaining[tp.Partition]
+		if !ok || tp.Offset >= high {
+			continue
+		}
+
+		read, err := visit(newDeadLetterMessage(kafkaMsg))
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case read == readStop:
+			return nil
+		case read == readStopPartition, tp.Offset+1 >= high:
+			// Later messages of a partition which is no longer remaining are dropped above
+			delete(remaining, tp.Partition)
+		}
+	}
+
+	return nil
+}
+
+// dropReachedPartitions remove partitions whose position reached their high watermark
+func dropReachedPartitions(consumer *kafka.Consumer, assigned []kafka.TopicPartition, remaining map[int32]kafka.Offset) {
+	positions, err := consumer.Position(assigned)
+	if err != nil {
+		return
+	}
+
+	for _, tp := range positions {
+		if high, ok := remaining[tp.Partition]; ok && tp.Offset >= high {
+			delete(remaining, tp.Partition)
+		}
+	}
+}
+
+// topicPartitions return partitions of the topic, a topic which does not exist has no partition
+func topicPartitions(consumer *kafka.Consumer, topic string) ([]int32, error) {
+	metadata, err := consumer.GetMetadata(&topic, false, int(deadLetterTimeout.Milliseconds()))
+	if err != nil {
+		return nil, err
+	}
+
+	topicMetadata, ok := metadata.Topics[topic]
+	if !ok || topicMetadata.Error.Code() == kafka.ErrUnknownTopicOrPart {
+		return nil, nil
+	}
+	if topicMetadata.Error.Code() != kafka.ErrNoError {
+		return nil, topicMetadata.Error
+	}
+
+	partitions := make([]int32, 0, len(topicMetadata.Partitions))
+	for _, partition := range topicMetadata.Partitions {
+		partitions = append(partitions, partition.ID)
+	}
+
+	return partitions, nil
+}
+
+// containsPartition report whether partition is one of partitions
+func containsPartition(partitions []int32, partition int32) bool {
+	for _, p := range partitions {
+		if p == partition {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isTimeout report whether the consumer read timed out without message
+func isTimeout(err error) bool {
+	var kafkaErr kafka.Error
+	return errors.As(err, &kafkaErr) && kafkaErr.Code() == kafka.ErrTimedOut
+}
+
+// newDeadLetterMessage decode dead-letter message and its failure headers, headers of the original message are kept
+func newDeadLetterMessage(kafkaMsg *kafka.Message) entities.DeadLetterMessage {
+	msg := entities.DeadLetterMessage{
+		Partition:         kafkaMsg.TopicPartition.Partition,
+		Offset:            int64(kafkaMsg.TopicPartition.Offset),
+		Key:               string(kafkaMsg.Key),
+		Value:             string(kafkaMsg.Value),
+		Timestamp:         kafkaMsg.Timestamp,
+		OriginalPartition: -1,
+		OriginalOffset:    -1,
+	}
+	if kafkaMsg.TopicPartition.Topic != nil {
+		msg.Topic = *kafkaMsg.TopicPartition.Topic
+	}
+
+	for _, header := range kafkaMsg.Headers {
+		value := string(header.Value)
+
+		switch header.Key {
+		case ckafka.HeaderOriginalTopic:
+			msg.OriginalTopic = value
+		case ckafka.HeaderOriginalPartition:
+			if partition, err := strconv.ParseInt(value, 10, 32); err == nil {
+				msg.OriginalPartition = int32(partition)
+			}
+		case ckafka.HeaderOriginalOffset:
+			if offset, err := strconv.ParseInt(value, 10, 64); err == nil {
+				msg.OriginalOffset = offset
+			}
+		case ckafka.HeaderError:
+			msg.Error = value
+		case ckafka.HeaderErrorClass:
+			msg.ErrorClass = value
+		case ckafka.HeaderErrorType:
+			msg.ErrorType = value
+		case ckafka.HeaderAttempts:
+			if attempts, err := strconv.Atoi(value); err == nil {
+				msg.Attempts = attempts
+			}
+		case ckafka.HeaderFailedAt:
+			if failedAt, err := time.Parse(time.RFC3339Nano, value); err == nil {
+				msg.FailedAt = failedAt
+			}
+		case ckafka.HeaderConsumerHost:
+			msg.ConsumerHost = value
+		default:
+			if _, ok := ckafka.FailureHeaders[header.Key]; ok {
+				continue
+			}
+
+			if msg.Headers == nil {
+				msg.Headers = make(map[string]string)
+			}
+			msg.Headers[header.Key] = value
+		}
+	}
+
+	return msg
+}
@@ -5,12 +5,19 @@ package kafka
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
+
+	"message-service-kata/internal/app/infra"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 	"go.uber.org/dig"
 )
 
+// queueFullBackoff is the wait before retrying to enqueue message when producer queue is full
+const queueFullBackoff = 50 * time.Millisecond
+
 type (
 	// PublishData used to Create kafka publish message
 	PublishData struct {
@@ -29,6 +36,7 @@ type (
 	RepositoryKafka interface {
 		PublishWithKey(ctx context.Context, args PublishData) (err error)
 		PublishWithoutKey(ctx context.Context, args PublishData) (err error)
+		PublishAsync(ctx context.Context, args PublishData, onDelivery infra.DeliveryHandler) (err error)
 	}
 )
 
@@ -53,16 +61,14 @@ func (ox *RepositoryKafkaImpl) PublishWithKey(ctx context.Context, args PublishD
 		Key:   []byte(args.Key),
 	}
 
-	deliverChan := make(chan kafka.Event)
+	deliverChan := make(chan kafka.Event, 1)
 	err = ox.KafkaProduce.Produce(msg, deliverChan)
 	if err != nil {
-		err = fmt.Errorf("[repository][PublishWithKey] while producing : %v", err)
+		// message is not enqueued, so no delivery report will be sent
+		return fmt.Errorf("[repository][PublishWithKey] while producing : %w", err)
 	}
 
 	kafkEvent := <-deliverChan
-	if err != nil {
-		return err
-	}
 
 	msg = kafkEvent.(*kafka.Message)
 	if msg.TopicPartition.Error != nil {
@@ -88,17 +94,14 @@ func (ox *RepositoryKafkaImpl) PublishWithoutKey(ctx context.Context, args Publi
 		Value: byt,
 	}
 
-	deliverChan := make(chan kafka.Event)
+	deliverChan := make(chan kafka.Event, 1)
 	err = ox.KafkaProduce.Produce(msg, deliverChan)
 	if err != nil {
-		err = fmt.Errorf("[repository][PublishWithoutKey] while producing : %v", err)
-		deliverChan <- nil
+		// message is not enqueued, so no delivery report will be sent
+		return fmt.Errorf("[repository][PublishWithoutKey] while producing : %w", err)
 	}
 
 	kafkEvent := <-deliverChan
-	if err != nil {
-		return err
-	}
 
 	msg = kafkEvent.(*kafka.Message)
 	if msg.TopicPartition.Error != nil {
@@ -107,3 +110,48 @@ func (ox *RepositoryKafkaImpl) PublishWithoutKey(ctx context.Context, args Publi
 	}
 	return nil
 }
+
+// PublishAsync function to publish kafka message without waiting for its delivery report. The Key is used when
+// it is not empty. onDelivery is called by the shared delivery report handler of the producer once the message
+// is delivered or failed, it is not called when error is returned.
+func (ox *RepositoryKafkaImpl) PublishAsync(
+	ctx context.Context, args PublishData, onDelivery infra.DeliveryHandler,
+) (err error) {
+	byt, err := json.Marshal(args.Data)
+	if err != nil {
+		return err
+	}
+
+	msg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{
+			Topic:     &args.Topic,
+			Partition: kafka.PartitionAny,
+		},
+		Value:  byt,
+		Opaque: onDelivery,
+	}
+	if args.Key != "" {
+		msg.Key = []byte(args.Key)
+	}
+
+	for {
+		err = ox.KafkaProduce.Produce(msg, nil)
+
+		// Apply backpressure until the producer queue has room again
+		var kafkaErr kafka.Error
+		if !errors.As(err, &kafkaErr) || kafkaErr.Code() != kafka.ErrQueueFull {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("[repository][PublishAsync] while waiting producer queue : %w", ctx.Err())
+		case <-time.After(queueFullBackoff):
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("[repository][PublishAsync] while producing : %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package postgres
+
+//go:generate mockery --dir=$PROJECT_DIR/internal/app/repo/postgres  --name=BatchRepository --filename=$GOFILE --output=$PROJECT_DIR/internal/generated/mock_postgres --outpkg=mock_postgres
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"message-service-kata/internal/app/repo/postgres/queries"
+
+	"go.uber.org/dig"
+
+	"message-service-kata/pkg/cerror"
+	"message-service-kata/pkg/domain/entities"
+)
+
+type (
+	// BatchRepositoryImpl Implementing batch repository dependency
+	BatchRepositoryImpl struct {
+		dig.In
+		*sql.DB
+	}
+
+	// BatchRepository interfacing Batch Repository function
+	BatchRepository interface {
+		// create
+		Create(ctx context.Context, args *entities.MessageBatch) (err error)
+
+		// update
+		IncrementCounters(ctx context.Context, batchID int64, args entities.BatchCounters) (err error)
+		CountMessage(ctx context.Context, batchID int64, messageID string) (err error)
+
+		// read
+		GetByID(ctx context.Context, id int64) (batch entities.MessageBatch, err error)
+	}
+)
+
+// NewBatchRepository initiate batch repository
+func NewBatchRepository(impl BatchRepositoryImpl) BatchRepository {
+	return &impl
+}
+
+// Create - function for store message batch, generated fields are assigned back to args
+func (r *BatchRepositoryImpl) Create(ctx context.Context, args *entities.MessageBatch) (err error) {
+	return r.DB.QueryRowContext(
+		ctx,
+		queries.QueryCreateBatch,
+		args.TriggerBy,
+		args.Requested,
+	).Scan(&args.ID, &args.CreatedAt, &args.UpdatedAt)
+}
+
+// IncrementCounters - function for increment message batch counters atomically
+func (r *BatchRepositoryImpl) IncrementCounters(ctx context.Context, batchID int64, args entities.BatchCounters) (err error) {
+	_, err = r.DB.ExecContext(
+		ctx,
+		queries.QueryIncrementBatchCounters,
+		batchID,
+		args.Published,
+		args.Failed,
+		args.Consumed,
+		args.Persisted,
+	)
+
+	return err
+}
+
+// CountMessage - function for count stored message of batch as consumed and persisted, a message which
+// is already counted is ignored so redelivery does not count it again
+func (r *BatchRepositoryImpl) CountMessage(ctx context.Context, batchID int64, messageID string) (err error) {
+	_, err = r.DB.ExecContext(ctx, queries.QueryCountBatchMessage, batchID, messageID)
+
+	return err
+}
+
+// GetByID - function for get message batch by id
+func (r *BatchRepositoryImpl) GetByID(ctx context.Context, id int64) (batch entities.MessageBatch, err error) {
+	err = r.DB.QueryRowContext(ctx, queries.QueryGetBatchByID, id).Scan(
+		&batch.ID,
+		&batch.TriggerBy,
+		&batch.Requested,
+		&batch.Published,
+		&batch.Failed,
+		&batch.Consumed,
+		&batch.Persisted,
+		&batch.CreatedAt,
+		&batch.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return batch, cerror.ErrNoRowsMessage
+	}
+	if err != nil {
+		return batch, err
+	}
+
+	batch.Status = batch.ResolveStatus()
+
+	return batch, nil
+}
@@ -0,0 +1,76 @@
+package postgres
+
+//go:generate mockery --dir=$PROJECT_DIR/internal/app/repo/postgres  --name=ConversationRepository --filename=$GOFILE --output=$PROJECT_DIR/internal/generated/mock_postgres --outpkg=mock_postgres
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"message-service-kata/internal/app/repo/postgres/queries"
+
+	"go.uber.org/dig"
+
+	"message-service-kata/pkg/cerror"
+	"message-service-kata/pkg/domain/entities"
+)
+
+type (
+	// ConversationRepositoryImpl Implementing conversation repository dependency
+	ConversationRepositoryImpl struct {
+		dig.In
+		*sql.DB
+	}
+
+	// ConversationRepository interfacing Conversation Repository function, conversations are
+	// created together with their first message by MessageRepository
+	ConversationRepository interface {
+		// read
+		GetByID(ctx context.Context, id, triggerBy string) (conversation entities.Conversation, err error)
+		CheckOwner(ctx context.Context, id, triggerBy string) (err error)
+	}
+)
+
+// NewConversationRepository initiate conversation repository
+func NewConversationRepository(impl ConversationRepositoryImpl) ConversationRepository {
+	return &impl
+}
+
+// GetByID - function for get conversation of the user by id without its messages, conversation of another user
+// is not found
+func (r *ConversationRepositoryImpl) GetByID(
+	ctx context.Context, id, triggerBy string,
+) (conversation entities.Conversation, err error) {
+	err = r.DB.QueryRowContext(ctx, queries.QueryGetConversationByID, id, triggerBy).Scan(
+		&conversation.ID,
+		&conversation.TriggerBy,
+		&conversation.CreatedAt,
+		&conversation.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return conversation, cerror.ErrNoRowsMessage
+	}
+	if err != nil {
+		return conversation, err
+	}
+
+	return conversation, nil
+}
+
+// CheckOwner - function for check conversation belongs to the user, conversation which does not exist yet belongs to
+// the user who sends its first message
+func (r *ConversationRepositoryImpl) CheckOwner(ctx context.Context, id, triggerBy string) (err error) {
+	var owner string
+	err = r.DB.QueryRowContext(ctx, queries.QueryGetConversationOwner, id).Scan(&owner)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if owner != triggerBy {
+		return cerror.ErrConversationOwner
+	}
+
+	return nil
+}
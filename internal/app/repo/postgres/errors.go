@@ -0,0 +1,50 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+
+	"github.com/lib/pq"
+
+	"message-service-kata/pkg/cerror"
+)
+
+// ClassifyError wrap error returned by the repository with its class. Lost connection and unavailable server are
+// transient infrastructure errors, invalid data, constraint violations and conversation of another user are permanent,
+// others are retryable.
+func ClassifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, cerror.ErrConversationOwner) {
+		return cerror.Permanent(err)
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Class() {
+		// connection exception, insufficient resources, operator intervention, system error
+		case "08", "53", "57", "58":
+			return cerror.TransientInfra(err)
+		// data exception, integrity constraint violation
+		case "22", "23":
+			return cerror.Permanent(err)
+		default:
+			return cerror.Retryable(err)
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return cerror.TransientInfra(err)
+	}
+
+	return cerror.Retryable(err)
+}
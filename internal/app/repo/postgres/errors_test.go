@@ -0,0 +1,58 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/lib/pq"
+
+	"message-service-kata/pkg/cerror"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want cerror.ErrorClass
+	}{
+		{name: "connection failure", err: &pq.Error{Code: "08006"}, want: cerror.ClassTransientInfra},
+		{name: "too many connections", err: &pq.Error{Code: "53300"}, want: cerror.ClassTransientInfra},
+		{name: "admin shutdown", err: &pq.Error{Code: "57P01"}, want: cerror.ClassTransientInfra},
+		{name: "io error", err: &pq.Error{Code: "58030"}, want: cerror.ClassTransientInfra},
+		{name: "invalid text representation", err: &pq.Error{Code: "22P02"}, want: cerror.ClassPermanent},
+		{name: "unique violation", err: &pq.Error{Code: "23505"}, want: cerror.ClassPermanent},
+		{name: "foreign key violation", err: &pq.Error{Code: "23503"}, want: cerror.ClassPermanent},
+		{name: "serialization failure", err: &pq.Error{Code: "40001"}, want: cerror.ClassRetryable},
+		{name: "deadlock", err: &pq.Error{Code: "40P01"}, want: cerror.ClassRetryable},
+		{name: "wrapped sqlstate", err: fmt.Errorf("[repository][Create] while insert : %w", &pq.Error{Code: "23505"}), want: cerror.ClassPermanent},
+		{name: "conversation of another user", err: cerror.ErrConversationOwner, want: cerror.ClassPermanent},
+		{name: "bad connection", err: driver.ErrBadConn, want: cerror.ClassTransientInfra},
+		{name: "connection closed", err: io.EOF, want: cerror.ClassTransientInfra},
+		{name: "deadline exceeded", err: context.DeadlineExceeded, want: cerror.ClassTransientInfra},
+		{name: "network error", err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, want: cerror.ClassTransientInfra},
+		{name: "other error", err: errors.New("boom"), want: cerror.ClassRetryable},
+		{name: "already classified", err: cerror.Permanent(driver.ErrBadConn), want: cerror.ClassPermanent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ClassifyError(tt.err)
+			if !errors.Is(got, tt.err) {
+				t.Fatalf("ClassifyError() = %v, does not wrap %v", got, tt.err)
+			}
+
+			if class := cerror.ClassOf(got); class != tt.want {
+				t.Fatalf("ClassOf(ClassifyError()) = %s, want %s", class, tt.want)
+			}
+		})
+	}
+
+	if err := ClassifyError(nil); err != nil {
+		t.Fatalf("ClassifyError(nil) = %v, want nil", err)
+	}
+}
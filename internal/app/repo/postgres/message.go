@@ -4,12 +4,17 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 
 	"message-service-kata/internal/app/repo/postgres/queries"
 
 	"github.com/rs/zerolog/log"
 	"go.uber.org/dig"
 
+	"message-service-kata/pkg/cerror"
 	"message-service-kata/pkg/domain/entities"
 )
 
@@ -20,10 +25,22 @@ type (
 		*sql.DB
 	}
 
+	// DialogResponder build the message to store from the locked dialog session of the conversation
+	// and return the session to save with it
+	DialogResponder func(session entities.DialogSession) (message string, next entities.DialogSession, err error)
+
 	// MessageRepository interfacing Message Repository function
 	MessageRepository interface {
 		// create
 		Create(ctx context.Context, args *entities.MessageData) (messageID int64, err error)
+		CreateWithDialog(ctx context.Context, args *entities.MessageData, respond DialogResponder) (messageID int64, err error)
+
+		// read
+		List(ctx context.Context, args *entities.ListMessageRequest) (messages []entities.ConsumedMessage, err error)
+		ListAfter(ctx context.Context, afterID int64, triggerBy string, limit int) (messages []entities.ConsumedMessage, err error)
+		ListByConversation(ctx context.Context, conversationID string) (messages []entities.ConsumedMessage, err error)
+		GetByID(ctx context.Context, id int64) (message entities.ConsumedMessage, err error)
+		GetIDBySource(ctx context.Context, sourceType, sourceID string) (messageID int64, err error)
 	}
 )
 
@@ -47,20 +64,286 @@ func (r *MessageRepositoryImpl) Create(ctx context.Context, args *entities.Messa
 		}
 	}()
 
+	// Conversation is created by its first message
+	if args.ConversationID != "" {
+		err = upsertConversation(ctx, tx, args)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	messageID, err = insertMessage(ctx, tx, args)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return messageID, nil
+}
+
+// CreateWithDialog - function for store conversation message together with the dialog state of its conversation.
+// The conversation is locked until commit, so concurrent messages of the same conversation take turns.
+func (r *MessageRepositoryImpl) CreateWithDialog(
+	ctx context.Context, args *entities.MessageData, respond DialogResponder,
+) (messageID int64, err error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil && tx != nil {
+			errs := tx.Rollback()
+			if errs != nil {
+				log.Error().Any("error", errs).Msg("error process rollback")
+			}
+		}
+	}()
+
+	// Upserting the conversation locks its row, dialog state may not exist yet so it can not be the lock
+	err = upsertConversation(ctx, tx, args)
+	if err != nil {
+		return 0, err
+	}
+
+	session := entities.DialogSession{ConversationID: args.ConversationID}
+
+	var slots []byte
+	err = tx.QueryRowContext(ctx, queries.QueryGetDialogStateForUpdate, args.ConversationID).Scan(
+		&session.Flow,
+		&session.State,
+		&slots,
+		&session.UpdatedAt,
+	)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		err = nil
+	case err != nil:
+		return 0, err
+	default:
+		err = json.Unmarshal(slots, &session.Slots)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	message, next, err := respond(session)
+	if err != nil {
+		return 0, err
+	}
+
+	data := *args
+	data.Message = message
+
+	messageID, err = insertMessage(ctx, tx, &data)
+	if err != nil {
+		return 0, err
+	}
+
+	if next.Flow == "" {
+		_, err = tx.ExecContext(ctx, queries.QueryDeleteDialogState, args.ConversationID)
+	} else {
+		slots, err = json.Marshal(next.Slots)
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = tx.ExecContext(ctx, queries.QueryUpsertDialogState, args.ConversationID, next.Flow, next.State, string(slots))
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return messageID, nil
+}
+
+// List - function for list consumed message filtered by request, ordered by newest first
+func (r *MessageRepositoryImpl) List(
+	ctx context.Context, args *entities.ListMessageRequest,
+) (messages []entities.ConsumedMessage, err error) {
+	var (
+		conditions []string
+		params     []interface{}
+	)
+
+	addCondition := func(clause string, value interface{}) {
+		params = append(params, value)
+		conditions = append(conditions, fmt.Sprintf(clause, len(params)))
+	}
+
+	if args.TriggerBy != "" {
+		addCondition("trigger_by = $%d", args.TriggerBy)
+	}
+
+	if args.SourceType != "" {
+		addCondition("source_type = $%d", args.SourceType)
+	}
+
+	if !args.ReceivedFrom.IsZero() {
+		addCondition("received_at >= $%d", args.ReceivedFrom)
+	}
+
+	if !args.ReceivedTo.IsZero() {
+		addCondition("received_at < $%d", args.ReceivedTo)
+	}
+
+	// JSONB fields are matched using containment so the GIN index on message can be used
+	fields := map[string]string{}
+	if args.ReceivedMessage != "" {
+		fields["received_message"] = args.ReceivedMessage
+	}
+	if args.ResponseMessage != "" {
+		fields["response_message"] = args.ResponseMessage
+	}
+	if len(fields) > 0 {
+		byt, errs := json.Marshal(fields)
+		if errs != nil {
+			return nil, errs
+		}
+		addCondition("message @> $%d::jsonb", string(byt))
+	}
+
+	if args.Cursor > 0 {
+		addCondition("id < $%d", args.Cursor)
+	}
+
+	query := queries.QueryListMessage
+	if len(conditions) > 0 {
+		query += "\n\tWHERE " + strings.Join(conditions, " AND ")
+	}
+
+	params = append(params, args.Limit)
+	query += fmt.Sprintf("\n\tORDER BY id DESC\n\tLIMIT $%d;", len(params))
+
+	rows, err := r.DB.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanConsumedMessages(rows, args.Limit)
+}
+
+// ListAfter - function for list consumed message after the given id, ordered by oldest first
+func (r *MessageRepositoryImpl) ListAfter(
+	ctx context.Context, afterID int64, triggerBy string, limit int,
+) (messages []entities.ConsumedMessage, err error) {
+	rows, err := r.DB.QueryContext(ctx, queries.QueryListMessageAfter, afterID, triggerBy, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanConsumedMessages(rows, limit)
+}
+
+// ListByConversation - function for list consumed message of conversation, ordered by oldest first
+func (r *MessageRepositoryImpl) ListByConversation(
+	ctx context.Context, conversationID string,
+) (messages []entities.ConsumedMessage, err error) {
+	rows, err := r.DB.QueryContext(ctx, queries.QueryListMessageByConversation, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanConsumedMessages(rows, 0)
+}
+
+// GetByID - function for get consumed message by id
+func (r *MessageRepositoryImpl) GetByID(ctx context.Context, id int64) (message entities.ConsumedMessage, err error) {
+	err = r.DB.QueryRowContext(ctx, queries.QueryGetMessageByID, id).Scan(
+		&message.ID,
+		&message.Message,
+		&message.TriggerBy,
+		&message.ConversationID,
+		&message.ReceivedAt,
+		&message.SourceType,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return message, cerror.ErrNoRowsMessage
+	}
+	if err != nil {
+		return message, err
+	}
+
+	return message, nil
+}
+
+// GetIDBySource - function for get id of the message stored for the source
+func (r *MessageRepositoryImpl) GetIDBySource(ctx context.Context, sourceType, sourceID string) (messageID int64, err error) {
+	err = r.DB.QueryRowContext(ctx, queries.QueryGetMessageIDBySource, sourceType, sourceID).Scan(&messageID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, cerror.ErrNoRowsMessage
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return messageID, nil
+}
+
+// upsertConversation create conversation of the message or mark it as updated, conversation of another user
+// fails with cerror.ErrConversationOwner
+func upsertConversation(ctx context.Context, tx *sql.Tx, args *entities.MessageData) (err error) {
+	var id string
+	err = tx.QueryRowContext(ctx, queries.QueryUpsertConversation, args.ConversationID, args.TriggerBy).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return cerror.ErrConversationOwner
+	}
+
+	return err
+}
+
+// insertMessage store consumed message in the transaction, message of a source ID which is already stored
+// fails with cerror.ErrDuplicateMessage
+func insertMessage(ctx context.Context, tx *sql.Tx, args *entities.MessageData) (messageID int64, err error) {
 	err = tx.QueryRowContext(
 		ctx,
 		queries.QueryCreateMessage,
 		args.Message,
 		args.TriggerBy,
+		args.ConversationID,
+		args.SourceType,
+		args.SourceID,
 	).Scan(&messageID)
-	if err != nil {
-		return 0, err
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, cerror.ErrDuplicateMessage
 	}
-
-	err = tx.Commit()
 	if err != nil {
 		return 0, err
 	}
 
 	return messageID, nil
 }
+
+// scanConsumedMessages read consumed message rows
+func scanConsumedMessages(rows *sql.Rows, capacity int) (messages []entities.ConsumedMessage, err error) {
+	messages = make([]entities.ConsumedMessage, 0, capacity)
+	for rows.Next() {
+		var message entities.ConsumedMessage
+		err = rows.Scan(
+			&message.ID,
+			&message.Message,
+			&message.TriggerBy,
+			&message.ConversationID,
+			&message.ReceivedAt,
+			&message.SourceType,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, message)
+	}
+
+	return messages, rows.Err()
+}
@@ -0,0 +1,288 @@
+package postgres
+
+//go:generate mockery --dir=$PROJECT_DIR/internal/app/repo/postgres  --name=OutboxRepository --filename=$GOFILE --output=$PROJECT_DIR/internal/generated/mock_postgres --outpkg=mock_postgres
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+
+	"message-service-kata/internal/app/repo/postgres/queries"
+	"message-service-kata/pkg/cerror"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	"go.uber.org/dig"
+
+	"message-service-kata/pkg/domain/entities"
+)
+
+type (
+	// OutboxRepositoryImpl Implementing outbox repository dependency
+	OutboxRepositoryImpl struct {
+		dig.In
+		*sql.DB
+	}
+
+	// OutboxEnqueuer build the outbox messages of the created batch and request, every message is handed to enqueue
+	OutboxEnqueuer func(batch *entities.MessageBatch, request *entities.MessageRequest, enqueue func(message entities.OutboxMessage) error) error
+
+	// OutboxSender publish the claimed outbox messages in order. errs[i] is the delivery error of messages[i],
+	// messages reported with cerror.ErrOutboxHeldBack and messages after the last reported one are not attempted
+	// and stay pending untouched.
+	OutboxSender func(messages []entities.OutboxMessage) (errs []error)
+
+	// OutboxRepository interfacing Outbox Repository function
+	OutboxRepository interface {
+		// create
+		CreateRequest(ctx context.Context, batch *entities.MessageBatch, request *entities.MessageRequest, build OutboxEnqueuer) (err error)
+
+		// update
+		Relay(ctx context.Context, args entities.OutboxRelayRequest, send OutboxSender) (result entities.OutboxRelayResult, err error)
+	}
+)
+
+// NewOutboxRepository initiate outbox repository
+func NewOutboxRepository(impl OutboxRepositoryImpl) OutboxRepository {
+	return &impl
+}
+
+// CreateRequest - function for store message batch, message request and its outbox messages in one transaction,
+// generated fields are assigned back to batch and request
+func (r *OutboxRepositoryImpl) CreateRequest(
+	ctx context.Context, batch *entities.MessageBatch, request *entities.MessageRequest, build OutboxEnqueuer,
+) (err error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil && tx != nil {
+			errs := tx.Rollback()
+			if errs != nil {
+				log.Error().Any("error", errs).Msg("error process rollback")
+			}
+		}
+	}()
+
+	err = tx.QueryRowContext(ctx, queries.QueryCreateBatch, batch.TriggerBy, batch.Requested).
+		Scan(&batch.ID, &batch.CreatedAt, &batch.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	request.BatchID = batch.ID
+	err = tx.QueryRowContext(
+		ctx,
+		queries.QueryCreateMessageRequest,
+		request.BatchID,
+		request.TriggerBy,
+		request.ConversationID,
+		string(request.Payload),
+	).Scan(&request.ID, &request.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	// A request may hold many messages, they are streamed to postgres with COPY instead of one insert each
+	stmt, err := tx.PrepareContext(
+		ctx,
+		pq.CopyIn(queries.TableMessageOutbox, "request_id", "batch_id", "topic", "message_key", "payload"),
+	)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	err = build(batch, request, func(message entities.OutboxMessage) error {
+		var key sql.NullString
+		if message.Key != "" {
+			key = sql.NullString{String: message.Key, Valid: true}
+		}
+
+		_, err := stmt.ExecContext(ctx, request.ID, batch.ID, message.Topic, key, string(message.Payload))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// Flush the buffered rows
+	_, err = stmt.ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Relay - function for claim the oldest due outbox messages, hand them to send and mark the delivered ones as
+// sent in the same transaction. Claimed rows stay locked until commit, so concurrent relays skip them. Published
+// counter of the batch of every sent message is incremented with it. Failed message is delayed with backoff and
+// parked once it reached the max attempts, later messages of its key are not claimed while it waits.
+func (r *OutboxRepositoryImpl) Relay(
+	ctx context.Context, args entities.OutboxRelayRequest, send OutboxSender,
+) (result entities.OutboxRelayResult, err error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return result, err
+	}
+	defer func() {
+		if err != nil && tx != nil {
+			errs := tx.Rollback()
+			if errs != nil {
+				log.Error().Any("error", errs).Msg("error process rollback")
+			}
+		}
+	}()
+
+	messages, err := claimPendingOutbox(ctx, tx, args.Limit)
+	if err != nil {
+		return result, err
+	}
+
+	result.Claimed = len(messages)
+	if len(messages) == 0 {
+		err = tx.Commit()
+		return result, err
+	}
+
+	errs := send(messages)
+
+	sent := make([]int64, 0, len(errs))
+	published := make(map[int64]int64)
+	for i, sendErr := range errs {
+		message := messages[i]
+
+		if errors.Is(sendErr, cerror.ErrOutboxHeldBack) {
+			result.HeldBack++
+			continue
+		}
+
+		if sendErr != nil {
+			result.Failed++
+
+			var parked bool
+			err = tx.QueryRowContext(
+				ctx,
+				queries.QueryMarkOutboxFailed,
+				message.ID,
+				sendErr.Error(),
+				args.MaxAttempts,
+				args.RetryBackoff.Milliseconds(),
+				args.RetryBackoffMax.Milliseconds(),
+			).Scan(&parked)
+			if err != nil {
+				return result, err
+			}
+
+			if parked {
+				result.Parked++
+				log.Error().
+					Any("id", message.ID).
+					Any("topic", message.Topic).
+					Any("key", message.Key).
+					Any("attempts", message.Attempts+1).
+					Msgf("outbox message parked as failed : %v", sendErr)
+			}
+
+			continue
+		}
+
+		sent = append(sent, message.ID)
+		if message.BatchID != 0 {
+			published[message.BatchID]++
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, queries.QueryMarkOutboxSent, pq.Int64Array(sent))
+	if err != nil {
+		return result, err
+	}
+
+	for batchID, count := range published {
+		_, err = tx.ExecContext(ctx, queries.QueryIncrementBatchCounters, batchID, count, 0, 0, 0)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return result, err
+	}
+
+	result.Sent = len(sent)
+
+	return result, nil
+}
+
+// claimPendingOutbox lock and return the oldest due outbox messages which are first of their key, then fill the limit
+// with the messages following them in their key. Messages are returned in id order.
+func claimPendingOutbox(ctx context.Context, tx *sql.Tx, limit int) (messages []entities.OutboxMessage, err error) {
+	messages, err = scanOutboxMessages(tx.QueryContext(ctx, queries.QueryClaimPendingOutbox, limit))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(messages))
+	ids := make([]int64, 0, len(messages))
+	for _, message := range messages {
+		if message.Key != "" {
+			keys = append(keys, message.Key)
+		}
+		ids = append(ids, message.ID)
+	}
+
+	if len(keys) == 0 || len(messages) >= limit {
+		return messages, nil
+	}
+
+	followers, err := scanOutboxMessages(
+		tx.QueryContext(ctx, queries.QueryClaimOutboxFollowers, pq.StringArray(keys), pq.Int64Array(ids), limit-len(messages)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	messages = append(messages, followers...)
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].ID < messages[j].ID
+	})
+
+	return messages, nil
+}
+
+// scanOutboxMessages read claimed outbox message rows
+func scanOutboxMessages(rows *sql.Rows, err error) (messages []entities.OutboxMessage, _ error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var message entities.OutboxMessage
+		err = rows.Scan(
+			&message.ID,
+			&message.RequestID,
+			&message.BatchID,
+			&message.Topic,
+			&message.Key,
+			&message.Payload,
+			&message.Attempts,
+			&message.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, message)
+	}
+
+	return messages, rows.Err()
+}
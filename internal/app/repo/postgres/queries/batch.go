@@ -0,0 +1,39 @@
+package queries
+
+const (
+	// QueryCreateBatch query to create message batch
+	QueryCreateBatch = `
+	INSERT INTO message_batches (trigger_by, requested)
+	VALUES ($1, $2)
+	RETURNING id, created_at, updated_at;`
+
+	// QueryIncrementBatchCounters query to increment message batch counters
+	QueryIncrementBatchCounters = `
+	UPDATE message_batches
+	SET published = published + $2,
+		failed = failed + $3,
+		consumed = consumed + $4,
+		persisted = persisted + $5,
+		updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1;`
+
+	// QueryCountBatchMessage query to count stored message of batch as consumed and persisted, once per message id
+	QueryCountBatchMessage = `
+	WITH counted AS (
+		INSERT INTO message_batch_items (batch_id, message_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+		RETURNING batch_id
+	)
+	UPDATE message_batches
+	SET consumed = consumed + 1,
+		persisted = persisted + 1,
+		updated_at = CURRENT_TIMESTAMP
+	WHERE id IN (SELECT batch_id FROM counted);`
+
+	// QueryGetBatchByID query to get message batch by id
+	QueryGetBatchByID = `
+	SELECT id, COALESCE(trigger_by, ''), requested, published, failed, consumed, persisted, created_at, updated_at
+	FROM message_batches
+	WHERE id = $1;`
+)
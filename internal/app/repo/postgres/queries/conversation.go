@@ -0,0 +1,24 @@
+package queries
+
+const (
+	// QueryUpsertConversation query to create conversation or mark it as updated, conversation of another
+	// user is left untouched and returns no row
+	QueryUpsertConversation = `
+	INSERT INTO conversations (id, trigger_by)
+	VALUES ($1, $2)
+	ON CONFLICT (id) DO UPDATE SET updated_at = CURRENT_TIMESTAMP
+	WHERE conversations.trigger_by = EXCLUDED.trigger_by
+	RETURNING id;`
+
+	// QueryGetConversationByID query to get conversation of the user by id
+	QueryGetConversationByID = `
+	SELECT id, COALESCE(trigger_by, ''), created_at, updated_at
+	FROM conversations
+	WHERE id = $1 AND trigger_by = $2;`
+
+	// QueryGetConversationOwner query to get the user of conversation
+	QueryGetConversationOwner = `
+	SELECT COALESCE(trigger_by, '')
+	FROM conversations
+	WHERE id = $1;`
+)
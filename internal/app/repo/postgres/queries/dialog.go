@@ -0,0 +1,22 @@
+package queries
+
+const (
+	// QueryGetDialogStateForUpdate query to get dialog state of conversation and lock it until the transaction ends
+	QueryGetDialogStateForUpdate = `
+	SELECT flow, state, slots, updated_at
+	FROM dialog_states
+	WHERE conversation_id = $1
+	FOR UPDATE;`
+
+	// QueryUpsertDialogState query to save dialog state of conversation
+	QueryUpsertDialogState = `
+	INSERT INTO dialog_states (conversation_id, flow, state, slots)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (conversation_id) DO UPDATE
+	SET flow = EXCLUDED.flow, state = EXCLUDED.state, slots = EXCLUDED.slots, updated_at = CURRENT_TIMESTAMP;`
+
+	// QueryDeleteDialogState query to delete dialog state of conversation which becomes idle
+	QueryDeleteDialogState = `
+	DELETE FROM dialog_states
+	WHERE conversation_id = $1;`
+)
@@ -1,9 +1,42 @@
 package queries
 
 const (
-	// QueryCreateMessage query to create message
+	// QueryCreateMessage query to create message, no row is returned when message of the source is already stored
 	QueryCreateMessage = `
-	INSERT INTO consumed_messages (message, trigger_by)
-	VALUES ($1, $2)
+	INSERT INTO consumed_messages (message, trigger_by, conversation_id, source_type, source_id)
+	VALUES ($1, $2, NULLIF($3, ''), COALESCE(NULLIF($4, ''), 'chat'), NULLIF($5, ''))
+	ON CONFLICT (source_type, source_id) DO NOTHING
 	RETURNING id;`
+
+	// QueryListMessage query to list message, filter and pagination clause appended by repository
+	QueryListMessage = `
+	SELECT id, message, COALESCE(trigger_by, ''), COALESCE(conversation_id, ''), received_at, source_type
+	FROM consumed_messages`
+
+	// QueryListMessageAfter query to list message after the given id, oldest first
+	QueryListMessageAfter = `
+	SELECT id, message, COALESCE(trigger_by, ''), COALESCE(conversation_id, ''), received_at, source_type
+	FROM consumed_messages
+	WHERE id > $1 AND ($2::text = '' OR trigger_by = $2::text)
+	ORDER BY id ASC
+	LIMIT $3;`
+
+	// QueryListMessageByConversation query to list message of conversation, oldest first
+	QueryListMessageByConversation = `
+	SELECT id, message, COALESCE(trigger_by, ''), COALESCE(conversation_id, ''), received_at, source_type
+	FROM consumed_messages
+	WHERE conversation_id = $1
+	ORDER BY id ASC;`
+
+	// QueryGetMessageByID query to get message by id
+	QueryGetMessageByID = `
+	SELECT id, message, COALESCE(trigger_by, ''), COALESCE(conversation_id, ''), received_at, source_type
+	FROM consumed_messages
+	WHERE id = $1;`
+
+	// QueryGetMessageIDBySource query to get id of the message stored for the source
+	QueryGetMessageIDBySource = `
+	SELECT id
+	FROM consumed_messages
+	WHERE source_type = $1 AND source_id = $2;`
 )
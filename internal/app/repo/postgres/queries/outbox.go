@@ -0,0 +1,63 @@
+package queries
+
+const (
+	// TableMessageOutbox table of the outbox messages, rows are written with COPY
+	TableMessageOutbox = "message_outbox"
+
+	// QueryCreateMessageRequest query to create message request
+	QueryCreateMessageRequest = `
+	INSERT INTO message_requests (batch_id, trigger_by, conversation_id, payload)
+	VALUES ($1, $2, NULLIF($3, ''), $4)
+	RETURNING id, created_at;`
+
+	// QueryClaimPendingOutbox query to lock the oldest pending outbox messages which are due and first of their key,
+	// rows locked by another relay are skipped. Message behind any pending message of its key is not claimed, so a key
+	// is only published by the relay holding its first pending message and stays in order.
+	QueryClaimPendingOutbox = `
+	SELECT o.id, o.request_id, COALESCE(o.batch_id, 0), o.topic, COALESCE(o.message_key, ''), o.payload, o.attempts, o.created_at
+	FROM message_outbox o
+	WHERE o.sent_at IS NULL
+		AND o.failed_at IS NULL
+		AND (o.next_attempt_at IS NULL OR o.next_attempt_at <= CURRENT_TIMESTAMP)
+		AND NOT EXISTS (
+			SELECT 1
+			FROM message_outbox earlier
+			WHERE earlier.message_key = o.message_key
+				AND earlier.id < o.id
+				AND earlier.sent_at IS NULL
+				AND earlier.failed_at IS NULL
+		)
+	ORDER BY o.id
+	LIMIT $1
+	FOR UPDATE OF o SKIP LOCKED;`
+
+	// QueryClaimOutboxFollowers query to lock the pending outbox messages following the claimed first messages of their
+	// key, other relays do not claim them while the first message is pending.
+	QueryClaimOutboxFollowers = `
+	SELECT o.id, o.request_id, COALESCE(o.batch_id, 0), o.topic, COALESCE(o.message_key, ''), o.payload, o.attempts, o.created_at
+	FROM message_outbox o
+	WHERE o.sent_at IS NULL
+		AND o.failed_at IS NULL
+		AND o.message_key = ANY($1)
+		AND NOT o.id = ANY($2)
+	ORDER BY o.id
+	LIMIT $3
+	FOR UPDATE OF o;`
+
+	// QueryMarkOutboxSent query to mark outbox messages as sent
+	QueryMarkOutboxSent = `
+	UPDATE message_outbox
+	SET sent_at = CURRENT_TIMESTAMP
+	WHERE id = ANY($1);`
+
+	// QueryMarkOutboxFailed query to record failed publish attempt of outbox message. The next attempt is delayed by
+	// $4 milliseconds doubled on every attempt up to $5, the message is parked once it reached $3 attempts.
+	QueryMarkOutboxFailed = `
+	UPDATE message_outbox
+	SET attempts = attempts + 1,
+		last_error = $2,
+		next_attempt_at = CURRENT_TIMESTAMP + LEAST($4 * POWER(2, attempts), $5) * INTERVAL '1 millisecond',
+		failed_at = CASE WHEN attempts + 1 >= $3 THEN CURRENT_TIMESTAMP END
+	WHERE id = $1
+	RETURNING failed_at IS NOT NULL;`
+)
@@ -0,0 +1,32 @@
+package queries
+
+const (
+	// QueryCreateResponse query to create chatbot response
+	QueryCreateResponse = `
+	INSERT INTO chatbot_responses (intent, match_type, pattern, response, priority)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id, created_at, updated_at;`
+
+	// QueryUpdateResponse query to update chatbot response
+	QueryUpdateResponse = `
+	UPDATE chatbot_responses
+	SET intent = $2,
+		match_type = $3,
+		pattern = $4,
+		response = $5,
+		priority = $6,
+		updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1
+	RETURNING created_at, updated_at;`
+
+	// QueryDeleteResponse query to delete chatbot response
+	QueryDeleteResponse = `
+	DELETE FROM chatbot_responses
+	WHERE id = $1;`
+
+	// QueryListResponse query to list chatbot responses ordered by evaluation order
+	QueryListResponse = `
+	SELECT id, intent, match_type, pattern, response, priority, created_at, updated_at
+	FROM chatbot_responses
+	ORDER BY priority DESC, id ASC;`
+)
@@ -0,0 +1,125 @@
+package postgres
+
+//go:generate mockery --dir=$PROJECT_DIR/internal/app/repo/postgres  --name=ResponseRepository --filename=$GOFILE --output=$PROJECT_DIR/internal/generated/mock_postgres --outpkg=mock_postgres
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"message-service-kata/internal/app/repo/postgres/queries"
+
+	"go.uber.org/dig"
+
+	"message-service-kata/pkg/cerror"
+	"message-service-kata/pkg/domain/entities"
+)
+
+type (
+	// ResponseRepositoryImpl Implementing chatbot response repository dependency
+	ResponseRepositoryImpl struct {
+		dig.In
+		*sql.DB
+	}
+
+	// ResponseRepository interfacing chatbot Response Repository function
+	ResponseRepository interface {
+		// create
+		Create(ctx context.Context, args *entities.ChatbotResponse) (err error)
+
+		// update
+		Update(ctx context.Context, args *entities.ChatbotResponse) (err error)
+
+		// delete
+		Delete(ctx context.Context, id int64) (err error)
+
+		// read
+		List(ctx context.Context) (responses []entities.ChatbotResponse, err error)
+	}
+)
+
+// NewResponseRepository initiate chatbot response repository
+func NewResponseRepository(impl ResponseRepositoryImpl) ResponseRepository {
+	return &impl
+}
+
+// Create - function for store chatbot response, generated fields are assigned back to args
+func (r *ResponseRepositoryImpl) Create(ctx context.Context, args *entities.ChatbotResponse) (err error) {
+	return r.DB.QueryRowContext(
+		ctx,
+		queries.QueryCreateResponse,
+		args.Intent,
+		args.Type,
+		args.Pattern,
+		args.Response,
+		args.Priority,
+	).Scan(&args.ID, &args.CreatedAt, &args.UpdatedAt)
+}
+
+// Update - function for update chatbot response by id
+func (r *ResponseRepositoryImpl) Update(ctx context.Context, args *entities.ChatbotResponse) (err error) {
+	err = r.DB.QueryRowContext(
+		ctx,
+		queries.QueryUpdateResponse,
+		args.ID,
+		args.Intent,
+		args.Type,
+		args.Pattern,
+		args.Response,
+		args.Priority,
+	).Scan(&args.CreatedAt, &args.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return cerror.ErrNoRowsMessage
+	}
+
+	return err
+}
+
+// Delete - function for delete chatbot response by id
+func (r *ResponseRepositoryImpl) Delete(ctx context.Context, id int64) (err error) {
+	result, err := r.DB.ExecContext(ctx, queries.QueryDeleteResponse, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return cerror.ErrNoRowsMessage
+	}
+
+	return nil
+}
+
+// List - function for list every chatbot response ordered by evaluation order
+func (r *ResponseRepositoryImpl) List(ctx context.Context) (responses []entities.ChatbotResponse, err error) {
+	rows, err := r.DB.QueryContext(ctx, queries.QueryListResponse)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	responses = []entities.ChatbotResponse{}
+	for rows.Next() {
+		var response entities.ChatbotResponse
+		err = rows.Scan(
+			&response.ID,
+			&response.Intent,
+			&response.Type,
+			&response.Pattern,
+			&response.Response,
+			&response.Priority,
+			&response.CreatedAt,
+			&response.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		responses = append(responses, response)
+	}
+
+	return responses, rows.Err()
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 
 	controller "message-service-kata/internal/app/controller/rest"
+	"message-service-kata/pkg/middleware"
 
 	"github.com/labstack/echo/v4"
 	"github.com/rs/zerolog/log"
@@ -17,6 +18,39 @@ const (
 	// PostMessage - Create New messages api path
 	PostMessage = ContextPath + "post"
 
+	// ChatMessage - Send message and wait for the chatbot reply api path
+	ChatMessage = ContextPath + "chat"
+
+	// StreamMessage - Stream processed messages as server-sent events api path
+	StreamMessage = ContextPath + "stream"
+
+	// ChatGateway - Websocket chat gateway api path
+	ChatGateway = ContextPath + "ws"
+
+	// ListMessage - List consumed messages api path
+	ListMessage = ContextPath
+
+	// GetMessage - Get consumed message by id api path
+	GetMessage = ContextPath + ":id"
+
+	// GetBatch - Get message batch progress by id api path
+	GetBatch = ContextPath + "batches/:id"
+
+	// GetConversation - Get conversation transcript by id api path
+	GetConversation = ContextPath + "conversations/:id"
+
+	// ResponsePath - Chatbot responses admin api path
+	ResponsePath = ContextPath + "responses"
+
+	// ResponseDetailPath - Chatbot response by id admin api path
+	ResponseDetailPath = ResponsePath + "/:id"
+
+	// DeadLetterPath - Dead-letter queue admin api path
+	DeadLetterPath = ContextPath + "dead-letters"
+
+	// DeadLetterDetailPath - Dead-letter message by partition and offset admin api path
+	DeadLetterDetailPath = DeadLetterPath + "/:partition/:offset"
+
 	// HealthPath - Application health check api path
 	HealthPath = ContextPath + "health"
 )
@@ -25,9 +59,27 @@ const (
 func setRoute(
 	e *echo.Echo,
 	messageCtrl controller.MessageCtrl,
+	responseCtrl controller.ResponseCtrl,
+	gatewayCtrl controller.GatewayCtrl,
+	deadLetterCtrl controller.DeadLetterCtrl,
 ) {
 	// Public API
 	e.POST(PostMessage, messageCtrl.PostMessage)
+	e.POST(ChatMessage, messageCtrl.Chat)
+	e.GET(StreamMessage, messageCtrl.StreamMessage)
+	e.GET(ChatGateway, gatewayCtrl.Connect)
+	e.GET(ListMessage, messageCtrl.ListMessage)
+	e.GET(GetMessage, messageCtrl.GetMessage)
+	e.GET(GetBatch, messageCtrl.GetBatch)
+	e.GET(GetConversation, messageCtrl.GetConversation)
+
+	// Admin API
+	e.GET(ResponsePath, responseCtrl.ListResponse, middleware.StrictMiddleware)
+	e.POST(ResponsePath, responseCtrl.CreateResponse, middleware.StrictMiddleware)
+	e.PUT(ResponseDetailPath, responseCtrl.UpdateResponse, middleware.StrictMiddleware)
+	e.DELETE(ResponseDetailPath, responseCtrl.DeleteResponse, middleware.StrictMiddleware)
+	e.GET(DeadLetterPath, deadLetterCtrl.ListDeadLetter, middleware.StrictMiddleware)
+	e.GET(DeadLetterDetailPath, deadLetterCtrl.GetDeadLetter, middleware.StrictMiddleware)
 
 	e.GET(HealthPath, messageCtrl.Health)
 }
@@ -0,0 +1,150 @@
+package service
+
+//go:generate mockery --dir=$PROJECT_DIR/internal/app/service  --name=ChatSvc --filename=$GOFILE --output=$PROJECT_DIR/internal/generated/mock_service --outpkg=mock_service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"message-service-kata/internal/app/infra"
+	"message-service-kata/internal/app/repo/kafka"
+	"message-service-kata/internal/app/repo/postgres"
+	"message-service-kata/pkg/cerror"
+	"message-service-kata/pkg/domain/entities"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"go.uber.org/dig"
+)
+
+type (
+	// ChatSvc interfacing chat service function
+	ChatSvc interface {
+		Chat(ctx context.Context, args *entities.ChatRequest) (reply entities.MessageReplyEvent, err error)
+		OpenSession() (sessionID string, replies <-chan entities.MessageReplyEvent, closeSession func())
+		Send(ctx context.Context, sessionID string, args *entities.ChatRequest) (messageID string, err error)
+		DispatchReply(ctx context.Context, reply entities.MessageReplyEvent) (err error)
+	}
+
+	// ChatSvcImpl implementing chat service dependencies
+	ChatSvcImpl struct {
+		dig.In
+		KafkaRepo        kafka.RepositoryKafka
+		ConversationRepo postgres.ConversationRepository
+		KafkaCfg         *infra.KafkaCfg
+		AppCfg           *infra.AppCfg
+		ReplyRegistry    ReplyRegistry
+	}
+)
+
+// NewChatSvc initiating chat service
+func NewChatSvc(impl ChatSvcImpl) ChatSvc {
+	return &impl
+}
+
+// Chat service to publish a single message and wait for its chatbot reply on the reply topic
+func (s *ChatSvcImpl) Chat(
+	ctx context.Context, args *entities.ChatRequest,
+) (reply entities.MessageReplyEvent, err error) {
+	if s.KafkaCfg.ReplyTopic == "" {
+		return reply, cerror.ErrReplyDisabled
+	}
+
+	messageID := uuid.NewString()
+
+	// Register before publishing so a fast reply is not missed
+	replyCh, unregister := s.ReplyRegistry.Register(messageID)
+	defer unregister()
+
+	err = s.publish(ctx, messageID, "", args)
+	if err != nil {
+		return reply, err
+	}
+
+	timer := time.NewTimer(s.AppCfg.ChatTimeout)
+	defer timer.Stop()
+
+	select {
+	case reply = <-replyCh:
+		return reply, nil
+	case <-timer.C:
+		log.Error().Msgf("[ChatSvc][Chat] no reply of message %s after %s", messageID, s.AppCfg.ChatTimeout)
+		return reply, fmt.Errorf("%w: message %s", cerror.ErrReplyTimeout, messageID)
+	case <-ctx.Done():
+		return reply, ctx.Err()
+	}
+}
+
+// OpenSession service to open chat session receiving the replies of every message it sends,
+// closeSession must be called once the session ends
+func (s *ChatSvcImpl) OpenSession() (sessionID string, replies <-chan entities.MessageReplyEvent, closeSession func()) {
+	sessionID = uuid.NewString()
+	replies, closeSession = s.ReplyRegistry.RegisterSession(sessionID, s.AppCfg.WSSendBuffer)
+
+	return sessionID, replies, closeSession
+}
+
+// Send service to publish chat message of the session without waiting for its reply
+func (s *ChatSvcImpl) Send(ctx context.Context, sessionID string, args *entities.ChatRequest) (messageID string, err error) {
+	if s.KafkaCfg.ReplyTopic == "" {
+		return "", cerror.ErrReplyDisabled
+	}
+
+	messageID = uuid.NewString()
+
+	err = s.publish(ctx, messageID, sessionID, args)
+	if err != nil {
+		return "", err
+	}
+
+	return messageID, nil
+}
+
+// publish publish chat message keyed by its conversation, the request key or the message ID in that order.
+// Message to conversation of another user is rejected before publishing.
+func (s *ChatSvcImpl) publish(ctx context.Context, messageID, sessionID string, args *entities.ChatRequest) (err error) {
+	if args.ConversationID != "" {
+		err = s.ConversationRepo.CheckOwner(ctx, args.ConversationID, args.TriggerBy)
+		if err != nil {
+			log.Error().Msgf("[ChatSvc] error while CheckOwner conversation %s : %v", args.ConversationID, err)
+			return err
+		}
+	}
+
+	key := args.Key
+	if args.ConversationID != "" {
+		key = args.ConversationID
+	}
+	if key == "" {
+		key = messageID
+	}
+
+	err = s.KafkaRepo.PublishWithKey(ctx, kafka.PublishData{
+		Topic: string(entities.TopicPublishMessage),
+		Key:   key,
+		Data: entities.MessageData{
+			ID:             messageID,
+			SessionID:      sessionID,
+			ConversationID: args.ConversationID,
+			Message:        args.Message,
+			TriggerBy:      args.TriggerBy,
+			Metadata:       args.Metadata,
+		},
+	})
+	if err != nil {
+		log.Error().Msgf("[ChatSvc] error while PublishWithKey message %s : %v", messageID, err)
+		return fmt.Errorf("%w: %v", cerror.ErrPublishMessage, err)
+	}
+
+	return nil
+}
+
+// DispatchReply service to hand reply consumed from the reply topic over to the waiting request or chat session
+func (s *ChatSvcImpl) DispatchReply(ctx context.Context, reply entities.MessageReplyEvent) (err error) {
+	if s.ReplyRegistry.Dispatch(reply) {
+		log.Info().Msgf("[ChatSvc][DispatchReply] reply of message %s delivered", reply.CorrelationID)
+	}
+
+	return nil
+}
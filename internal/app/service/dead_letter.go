@@ -0,0 +1,146 @@
+package service
+
+//go:generate mockery --dir=$PROJECT_DIR/internal/app/service  --name=DeadLetterSvc --filename=$GOFILE --output=$PROJECT_DIR/internal/generated/mock_service --outpkg=mock_service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"message-service-kata/internal/app/infra"
+	"message-service-kata/internal/app/repo/kafka"
+	"message-service-kata/pkg/domain/entities"
+
+	"github.com/rs/zerolog/log"
+	"go.uber.org/dig"
+)
+
+// defaultDeadLetterLimit is the number of dead-letter messages listed when no limit is requested
+const defaultDeadLetterLimit = 50
+
+type (
+	// DeadLetterSvc interfacing dead-letter queue service function
+	DeadLetterSvc interface {
+		ListDeadLetters(ctx context.Context, args *entities.ListDeadLetterRequest) (messages []entities.DeadLetterMessage, err error)
+		GetDeadLetter(ctx context.Context, args *entities.GetDeadLetterRequest) (message entities.DeadLetterMessage, err error)
+		ReplayDeadLetters(ctx context.Context, args *entities.ReplayDeadLetterRequest) (result entities.DeadLetterReplayResult, err error)
+	}
+
+	// DeadLetterSvcImpl implementing dead-letter queue service dependencies
+	DeadLetterSvcImpl struct {
+		dig.In
+		DeadLetterRepo kafka.DeadLetterRepository
+		KafkaCfg       *infra.KafkaCfg
+	}
+)
+
+// NewDeadLetterSvc initiating dead-letter queue service
+func NewDeadLetterSvc(impl DeadLetterSvcImpl) DeadLetterSvc {
+	return &impl
+}
+
+// ListDeadLetters service to list the oldest dead-letter messages of the original topic which pass the filter, among
+// the messages the repository scans at most
+func (s *DeadLetterSvcImpl) ListDeadLetters(
+	ctx context.Context, args *entities.ListDeadLetterRequest,
+) (messages []entities.DeadLetterMessage, err error) {
+	limit := args.Limit
+	if limit == 0 {
+		limit = defaultDeadLetterLimit
+	}
+
+	messages = make([]entities.DeadLetterMessage, 0)
+	err = s.DeadLetterRepo.Scan(ctx, args.Topic, func(msg entities.DeadLetterMessage) (bool, error) {
+		if args.Match(&msg) {
+			messages = append(messages, msg)
+		}
+
+		return len(messages) < limit, nil
+	})
+	if err != nil {
+		log.Error().Msgf("[DeadLetterSvc][ListDeadLetters] error while Scan dead-letter queue : %v", err)
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// GetDeadLetter service to get dead-letter message of the original topic
+func (s *DeadLetterSvcImpl) GetDeadLetter(
+	ctx context.Context, args *entities.GetDeadLetterRequest,
+) (message entities.DeadLetterMessage, err error) {
+	message, err = s.DeadLetterRepo.Get(ctx, args.Topic, args.Partition, args.Offset)
+	if err != nil {
+		log.Error().Msgf("[DeadLetterSvc][GetDeadLetter] error while Get dead-letter message : %v", err)
+		return message, err
+	}
+
+	return message, nil
+}
+
+// ReplayDeadLetters service to republish dead-letter messages of the original topic which pass the filter to their
+// original topic at most Rate messages per second. Offsets are committed as messages are republished. A message filtered
+// out stops its partition so the group never commits past it, whether the group is the default one or GroupID. Dry run
+// only counts the messages a replay would republish and commits nothing.
+func (s *DeadLetterSvcImpl) ReplayDeadLetters(
+	ctx context.Context, args *entities.ReplayDeadLetterRequest,
+) (result entities.DeadLetterReplayResult, err error) {
+	result.DryRun = args.DryRun
+
+	groupID := args.GroupID
+	if groupID == "" {
+		groupID = fmt.Sprintf("%s-dlq-replay", s.KafkaCfg.GroupID)
+	}
+
+	var ticker *time.Ticker
+	if args.Rate > 0 && !args.DryRun {
+		ticker = time.NewTicker(time.Duration(float64(time.Second) / args.Rate))
+		defer ticker.Stop()
+	}
+
+	err = s.DeadLetterRepo.Consume(ctx, args.Topic, groupID, !args.DryRun, func(msg entities.DeadLetterMessage) (bool, error) {
+		result.Scanned++
+		if !args.Match(&msg) {
+			result.StoppedPartitions++
+			log.Info().
+				Any("partition", msg.Partition).
+				Any("offset", msg.Offset).
+				Any("error_class", msg.ErrorClass).
+				Msg("dead-letter partition stopped at message filtered out")
+			return false, nil
+		}
+		result.Matched++
+
+		if args.DryRun {
+			log.Info().
+				Any("partition", msg.Partition).
+				Any("offset", msg.Offset).
+				Any("key", msg.Key).
+				Any("error_class", msg.ErrorClass).
+				Any("error", msg.Error).
+				Msg("dead-letter message would be replayed")
+			return true, nil
+		}
+
+		if ticker != nil {
+			select {
+			case <-ctx.Done():
+				return false, ctx.Err()
+			case <-ticker.C:
+			}
+		}
+
+		if err := s.DeadLetterRepo.Republish(ctx, msg); err != nil {
+			return false, fmt.Errorf("republish %s[%d]@%d : %w", msg.Topic, msg.Partition, msg.Offset, err)
+		}
+		result.Replayed++
+
+		return true, nil
+	})
+	if err != nil {
+		log.Error().Msgf("[DeadLetterSvc][ReplayDeadLetters] error while Consume dead-letter queue : %v", err)
+		return result, err
+	}
+
+	return result, nil
+}
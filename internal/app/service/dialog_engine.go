@@ -0,0 +1,312 @@
+package service
+
+//go:generate mockery --dir=$PROJECT_DIR/internal/app/service  --name=DialogEngine --filename=$GOFILE --output=$PROJECT_DIR/internal/generated/mock_service --outpkg=mock_service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"message-service-kata/internal/app/infra"
+	"message-service-kata/pkg/domain/entities"
+
+	"github.com/rs/zerolog/log"
+)
+
+type (
+	// DialogEngine interfacing multi-turn dialog engine function
+	DialogEngine interface {
+		Respond(
+			args entities.MessageData, session entities.DialogSession,
+		) (match entities.ResponseMatch, next entities.DialogSession, handled bool)
+	}
+
+	// fsmDialogEngine implementing dialog engine as finite state machine per conversation
+	fsmDialogEngine struct {
+		// flows keep configured order since triggers are evaluated flow by flow
+		flows []*compiledFlow
+		// ttl is the idle time after which a dialog is abandoned, zero keeps dialogs forever
+		ttl time.Duration
+	}
+
+	// compiledFlow is a dialog flow prepared for matching
+	compiledFlow struct {
+		name     string
+		triggers []compiledTransition
+		states   map[string]*compiledState
+	}
+
+	// compiledState is a dialog state prepared for matching, fallback is nil when the state has none
+	compiledState struct {
+		transitions []compiledTransition
+		fallback    *template.Template
+	}
+
+	// compiledTransition is a dialog transition prepared for matching
+	compiledTransition struct {
+		compiledRule
+		slot string
+		next string
+	}
+)
+
+// NewDialogEngine initiating dialog engine from flow set, dialogs idle for longer than ttl are abandoned
+func NewDialogEngine(flowSet entities.DialogFlowSet, ttl time.Duration) (DialogEngine, error) {
+	engine := &fsmDialogEngine{
+		flows: make([]*compiledFlow, 0, len(flowSet.Flows)),
+		ttl:   ttl,
+	}
+
+	names := make(map[string]struct{}, len(flowSet.Flows))
+	for i, flow := range flowSet.Flows {
+		if flow.Name == "" {
+			return nil, fmt.Errorf("flow %d: name is required", i)
+		}
+
+		if _, ok := names[flow.Name]; ok {
+			return nil, fmt.Errorf("flow %s: duplicate name", flow.Name)
+		}
+		names[flow.Name] = struct{}{}
+
+		compiled, err := compileFlow(flow)
+		if err != nil {
+			return nil, fmt.Errorf("flow %s: %w", flow.Name, err)
+		}
+
+		engine.flows = append(engine.flows, compiled)
+	}
+
+	return engine, nil
+}
+
+// NewConfiguredDialogEngine initiating dialog engine from the file configured by APP_DIALOGS_FILE,
+// or from the predefined dialog flows when no file is configured
+func NewConfiguredDialogEngine(cfg *infra.AppCfg) (DialogEngine, error) {
+	flowSet := entities.DefaultDialogFlows
+
+	if cfg.DialogsFile != "" {
+		flowSet = entities.DialogFlowSet{}
+
+		err := decodeConfigFile(cfg.DialogsFile, &flowSet)
+		if err != nil {
+			return nil, fmt.Errorf("load dialogs file %s: %w", cfg.DialogsFile, err)
+		}
+	}
+
+	engine, err := NewDialogEngine(flowSet, cfg.DialogTTL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dialog flows: %w", err)
+	}
+
+	return engine, nil
+}
+
+// Respond advance dialog of the conversation. The transitions of the active state are matched first, then the
+// triggers of every flow. Message which is not part of a dialog is not handled and leaves the conversation idle.
+func (e *fsmDialogEngine) Respond(
+	args entities.MessageData, session entities.DialogSession,
+) (match entities.ResponseMatch, next entities.DialogSession, handled bool) {
+	message := strings.TrimSpace(args.Message)
+	now := time.Now()
+
+	data := entities.ResponseTemplateData{
+		TriggerBy: args.TriggerBy,
+		Message:   args.Message,
+		Now:       entities.TemplateTime{Time: now},
+		Metadata:  args.Metadata,
+	}
+
+	flow, state := e.activeState(session, now)
+	if state != nil {
+		for _, transition := range state.transitions {
+			match, next, handled = transition.take(flow.name, args, message, data, session.Slots)
+			if handled {
+				return match, next, true
+			}
+		}
+
+		// Unexpected answer keeps the dialog in the same state
+		if state.fallback != nil {
+			data.Slots = session.Slots
+
+			response, err := renderResponse(state.fallback, data)
+			if err == nil {
+				next = session
+				next.ConversationID = args.ConversationID
+				next.UpdatedAt = now
+
+				return entities.ResponseMatch{Response: response}, next, true
+			}
+
+			log.Error().Msgf("[DialogEngine] error while render fallback of %s/%s : %v", session.Flow, session.State, err)
+		}
+
+		log.Info().Msgf("[DialogEngine] conversation %s left dialog %s/%s", args.ConversationID, session.Flow, session.State)
+	}
+
+	for _, flow := range e.flows {
+		for _, trigger := range flow.triggers {
+			match, next, handled = trigger.take(flow.name, args, message, data, nil)
+			if handled {
+				return match, next, true
+			}
+		}
+	}
+
+	return entities.ResponseMatch{}, entities.DialogSession{ConversationID: args.ConversationID}, false
+}
+
+// activeState return the current state of session, state is nil when the conversation is idle,
+// the dialog expired or its flow is no longer configured
+func (e *fsmDialogEngine) activeState(session entities.DialogSession, now time.Time) (*compiledFlow, *compiledState) {
+	if session.Flow == "" {
+		return nil, nil
+	}
+
+	if e.ttl > 0 && now.Sub(session.UpdatedAt) > e.ttl {
+		log.Info().Msgf("[DialogEngine] conversation %s dialog %s/%s expired", session.ConversationID, session.Flow, session.State)
+		return nil, nil
+	}
+
+	for _, flow := range e.flows {
+		if flow.name == session.Flow {
+			return flow, flow.states[session.State]
+		}
+	}
+
+	return nil, nil
+}
+
+// take match message against the transition and return the reply with the session after the transition
+func (t *compiledTransition) take(
+	flowName string, args entities.MessageData, message string, data entities.ResponseTemplateData, slots map[string]string,
+) (match entities.ResponseMatch, next entities.DialogSession, handled bool) {
+	matched, groups, namedGroups := t.match(message)
+	if !matched {
+		return match, next, false
+	}
+
+	nextSlots := make(map[string]string, len(slots)+1)
+	for name, value := range slots {
+		nextSlots[name] = value
+	}
+
+	if t.slot != "" {
+		value := message
+		if group := strings.TrimSpace(namedGroups[t.slot]); group != "" {
+			value = group
+		}
+		nextSlots[t.slot] = value
+	}
+
+	data.Groups = groups
+	data.NamedGroups = namedGroups
+	data.Slots = nextSlots
+
+	response, err := renderResponse(t.template, data)
+	if err != nil {
+		log.Error().Msgf("[DialogEngine] error while render response of %s/%s : %v", flowName, t.Intent, err)
+		return match, next, false
+	}
+
+	next = entities.DialogSession{ConversationID: args.ConversationID, UpdatedAt: data.Now.Time}
+	if t.next != "" {
+		next.Flow = flowName
+		next.State = t.next
+		next.Slots = nextSlots
+	}
+
+	return entities.ResponseMatch{Intent: t.Intent, Score: 1, Response: response}, next, true
+}
+
+// compileFlow validate flow and prepare its triggers and states for matching
+func compileFlow(flow entities.DialogFlow) (compiled *compiledFlow, err error) {
+	if len(flow.Triggers) == 0 {
+		return nil, errors.New("at least one trigger is required")
+	}
+
+	compiled = &compiledFlow{
+		name:     flow.Name,
+		triggers: make([]compiledTransition, 0, len(flow.Triggers)),
+		states:   make(map[string]*compiledState, len(flow.States)),
+	}
+
+	for i, state := range flow.States {
+		if state.Name == "" {
+			return nil, fmt.Errorf("state %d: name is required", i)
+		}
+
+		if _, ok := compiled.states[state.Name]; ok {
+			return nil, fmt.Errorf("state %s: duplicate name", state.Name)
+		}
+
+		compiled.states[state.Name] = &compiledState{}
+	}
+
+	for i, trigger := range flow.Triggers {
+		// Trigger which does not start the dialog is a plain response rule
+		if trigger.Next == "" {
+			return nil, fmt.Errorf("trigger %d: next state is required", i)
+		}
+
+		transition, err := compiled.compileTransition(trigger)
+		if err != nil {
+			return nil, fmt.Errorf("trigger %d: %w", i, err)
+		}
+
+		compiled.triggers = append(compiled.triggers, transition)
+	}
+
+	for _, state := range flow.States {
+		target := compiled.states[state.Name]
+
+		for i, transition := range state.Transitions {
+			stateTransition, err := compiled.compileTransition(transition)
+			if err != nil {
+				return nil, fmt.Errorf("state %s transition %d: %w", state.Name, i, err)
+			}
+
+			target.transitions = append(target.transitions, stateTransition)
+		}
+
+		if state.Fallback != "" {
+			target.fallback, err = parseResponseTemplate(state.Name, state.Fallback)
+			if err != nil {
+				return nil, fmt.Errorf("state %s fallback: %w", state.Name, err)
+			}
+		}
+	}
+
+	return compiled, nil
+}
+
+// compileTransition validate transition and prepare its pattern for matching, intent defaults to the flow name
+func (f *compiledFlow) compileTransition(transition entities.DialogTransition) (compiled compiledTransition, err error) {
+	if transition.Next != "" {
+		if _, ok := f.states[transition.Next]; !ok {
+			return compiled, fmt.Errorf("unknown next state: %s", transition.Next)
+		}
+	}
+
+	intent := transition.Intent
+	if intent == "" {
+		intent = f.name
+	}
+
+	compiled.compiledRule, err = compileRule(entities.ResponseRule{
+		Intent:   intent,
+		Type:     transition.Type,
+		Pattern:  transition.Pattern,
+		Response: transition.Response,
+	})
+	if err != nil {
+		return compiled, err
+	}
+
+	compiled.slot = transition.Slot
+	compiled.next = transition.Next
+
+	return compiled, nil
+}
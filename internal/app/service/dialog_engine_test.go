@@ -0,0 +1,321 @@
+package service
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"message-service-kata/pkg/domain/entities"
+)
+
+func TestCompileFlow(t *testing.T) {
+	trigger := entities.DialogTransition{Type: entities.MatchTypeExact, Pattern: "start", Response: "Started", Next: "asking"}
+	state := entities.DialogState{Name: "asking"}
+
+	tests := []struct {
+		name    string
+		flow    entities.DialogFlow
+		wantErr string
+	}{
+		{
+			name: "valid",
+			flow: entities.DialogFlow{
+				Name:     "flow",
+				Triggers: []entities.DialogTransition{trigger},
+				States: []entities.DialogState{{
+					Name: "asking",
+					Transitions: []entities.DialogTransition{
+						{Type: entities.MatchTypeRegex, Pattern: `^(?P<answer>.+)$`, Response: "{{.Slots.answer}}", Slot: "answer"},
+					},
+					Fallback: "Again?",
+				}},
+			},
+		},
+		{
+			name:    "no trigger",
+			flow:    entities.DialogFlow{Name: "flow", States: []entities.DialogState{state}},
+			wantErr: "at least one trigger is required",
+		},
+		{
+			name: "state without name",
+			flow: entities.DialogFlow{
+				Name:     "flow",
+				Triggers: []entities.DialogTransition{trigger},
+				States:   []entities.DialogState{state, {}},
+			},
+			wantErr: "state 1: name is required",
+		},
+		{
+			name: "duplicate state",
+			flow: entities.DialogFlow{
+				Name:     "flow",
+				Triggers: []entities.DialogTransition{trigger},
+				States:   []entities.DialogState{state, state},
+			},
+			wantErr: "state asking: duplicate name",
+		},
+		{
+			name: "trigger without next state",
+			flow: entities.DialogFlow{
+				Name:     "flow",
+				Triggers: []entities.DialogTransition{{Type: entities.MatchTypeExact, Pattern: "start", Response: "Started"}},
+				States:   []entities.DialogState{state},
+			},
+			wantErr: "trigger 0: next state is required",
+		},
+		{
+			name: "trigger to unknown state",
+			flow: entities.DialogFlow{
+				Name:     "flow",
+				Triggers: []entities.DialogTransition{{Type: entities.MatchTypeExact, Pattern: "start", Response: "Started", Next: "missing"}},
+				States:   []entities.DialogState{state},
+			},
+			wantErr: "trigger 0: unknown next state: missing",
+		},
+		{
+			name: "invalid trigger rule",
+			flow: entities.DialogFlow{
+				Name:     "flow",
+				Triggers: []entities.DialogTransition{{Type: entities.MatchTypeExact, Response: "Started", Next: "asking"}},
+				States:   []entities.DialogState{state},
+			},
+			wantErr: "trigger 0: pattern is required",
+		},
+		{
+			name: "transition to unknown state",
+			flow: entities.DialogFlow{
+				Name:     "flow",
+				Triggers: []entities.DialogTransition{trigger},
+				States: []entities.DialogState{{
+					Name: "asking",
+					Transitions: []entities.DialogTransition{
+						{Type: entities.MatchTypeExact, Pattern: "yes", Response: "Ok", Next: "missing"},
+					},
+				}},
+			},
+			wantErr: "state asking transition 0: unknown next state: missing",
+		},
+		{
+			name: "invalid fallback template",
+			flow: entities.DialogFlow{
+				Name:     "flow",
+				Triggers: []entities.DialogTransition{trigger},
+				States:   []entities.DialogState{{Name: "asking", Fallback: "{{.Message"}},
+			},
+			wantErr: "state asking fallback: invalid response template",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := compileFlow(tt.flow)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("compileFlow() error = %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("compileFlow() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewDialogEngine(t *testing.T) {
+	flow := entities.DialogFlow{
+		Name:     "flow",
+		Triggers: []entities.DialogTransition{{Type: entities.MatchTypeExact, Pattern: "start", Response: "Started", Next: "asking"}},
+		States:   []entities.DialogState{{Name: "asking"}},
+	}
+
+	tests := []struct {
+		name    string
+		flowSet entities.DialogFlowSet
+		wantErr string
+	}{
+		{name: "default flows", flowSet: entities.DefaultDialogFlows},
+		{name: "no flows", flowSet: entities.DialogFlowSet{}},
+		{
+			name:    "flow without name",
+			flowSet: entities.DialogFlowSet{Flows: []entities.DialogFlow{{Triggers: flow.Triggers, States: flow.States}}},
+			wantErr: "flow 0: name is required",
+		},
+		{
+			name:    "duplicate flow",
+			flowSet: entities.DialogFlowSet{Flows: []entities.DialogFlow{flow, flow}},
+			wantErr: "flow flow: duplicate name",
+		},
+		{
+			name:    "invalid flow",
+			flowSet: entities.DialogFlowSet{Flows: []entities.DialogFlow{{Name: "flow"}}},
+			wantErr: "flow flow: at least one trigger is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewDialogEngine(tt.flowSet, time.Minute)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("NewDialogEngine() error = %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("NewDialogEngine() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFsmDialogEngineRespond(t *testing.T) {
+	engine, err := NewDialogEngine(entities.DialogFlowSet{Flows: []entities.DialogFlow{
+		{
+			Name: "order",
+			Triggers: []entities.DialogTransition{
+				{Intent: "order", Type: entities.MatchTypeCaseInsensitive, Pattern: "order", Response: "What do you want?", Next: "ask_item"},
+			},
+			States: []entities.DialogState{
+				{
+					Name: "ask_item",
+					Transitions: []entities.DialogTransition{
+						{Intent: "cancel", Type: entities.MatchTypeExact, Pattern: "cancel", Response: "Cancelled"},
+						{
+							Intent:   "item",
+							Type:     entities.MatchTypeRegex,
+							Pattern:  `^(?:some )?(?P<item>.+)$`,
+							Response: "How many {{.Slots.item}}?",
+							Slot:     "item",
+							Next:     "ask_qty",
+						},
+					},
+				},
+				{
+					Name: "ask_qty",
+					Transitions: []entities.DialogTransition{
+						{
+							Type:     entities.MatchTypeRegex,
+							Pattern:  `^\d+$`,
+							Response: "Ordered {{.Slots.qty}} {{.Slots.item}}",
+							Slot:     "qty",
+						},
+					},
+					Fallback: "Please send a number of {{.Slots.item}}",
+				},
+			},
+		},
+	}}, time.Minute)
+	if err != nil {
+		t.Fatalf("NewDialogEngine() error = %v", err)
+	}
+
+	now := time.Now()
+	askItem := entities.DialogSession{ConversationID: "c1", Flow: "order", State: "ask_item", Slots: map[string]string{}, UpdatedAt: now}
+	askQty := entities.DialogSession{ConversationID: "c1", Flow: "order", State: "ask_qty", Slots: map[string]string{"item": "apples"}, UpdatedAt: now}
+
+	tests := []struct {
+		name        string
+		message     string
+		session     entities.DialogSession
+		wantHandled bool
+		wantMatch   entities.ResponseMatch
+		wantFlow    string
+		wantState   string
+		wantSlots   map[string]string
+	}{
+		{
+			name:        "trigger starts the flow",
+			message:     "Order",
+			session:     entities.DialogSession{ConversationID: "c1"},
+			wantHandled: true,
+			wantMatch:   entities.ResponseMatch{Intent: "order", Score: 1, Response: "What do you want?"},
+			wantFlow:    "order",
+			wantState:   "ask_item",
+			wantSlots:   map[string]string{},
+		},
+		{
+			name:    "message outside a dialog is not handled",
+			message: "hello",
+			session: entities.DialogSession{ConversationID: "c1"},
+		},
+		{
+			name:        "transition saves the named group to the slot",
+			message:     "some apples",
+			session:     askItem,
+			wantHandled: true,
+			wantMatch:   entities.ResponseMatch{Intent: "item", Score: 1, Response: "How many apples?"},
+			wantFlow:    "order",
+			wantState:   "ask_qty",
+			wantSlots:   map[string]string{"item": "apples"},
+		},
+		{
+			name:        "transition without next state ends the dialog",
+			message:     "cancel",
+			session:     askItem,
+			wantHandled: true,
+			wantMatch:   entities.ResponseMatch{Intent: "cancel", Score: 1, Response: "Cancelled"},
+		},
+		{
+			name:        "slot without named group saves the message",
+			message:     "3",
+			session:     askQty,
+			wantHandled: true,
+			wantMatch:   entities.ResponseMatch{Intent: "order", Score: 1, Response: "Ordered 3 apples"},
+		},
+		{
+			name:        "fallback keeps the state",
+			message:     "a lot",
+			session:     askQty,
+			wantHandled: true,
+			wantMatch:   entities.ResponseMatch{Response: "Please send a number of apples"},
+			wantFlow:    "order",
+			wantState:   "ask_qty",
+			wantSlots:   map[string]string{"item": "apples"},
+		},
+		{
+			name:    "expired dialog is abandoned",
+			message: "a lot",
+			session: entities.DialogSession{ConversationID: "c1", Flow: "order", State: "ask_qty", UpdatedAt: now.Add(-time.Hour)},
+		},
+		{
+			name:    "unknown flow is abandoned",
+			message: "a lot",
+			session: entities.DialogSession{ConversationID: "c1", Flow: "removed", State: "ask_qty", UpdatedAt: now},
+		},
+		{
+			name:        "expired dialog can be triggered again",
+			message:     "order",
+			session:     entities.DialogSession{ConversationID: "c1", Flow: "order", State: "ask_qty", UpdatedAt: now.Add(-time.Hour)},
+			wantHandled: true,
+			wantMatch:   entities.ResponseMatch{Intent: "order", Score: 1, Response: "What do you want?"},
+			wantFlow:    "order",
+			wantState:   "ask_item",
+			wantSlots:   map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, next, handled := engine.Respond(entities.MessageData{ConversationID: "c1", Message: tt.message}, tt.session)
+			if handled != tt.wantHandled {
+				t.Fatalf("Respond() handled = %v, want %v", handled, tt.wantHandled)
+			}
+
+			if match != tt.wantMatch {
+				t.Fatalf("Respond() match = %+v, want %+v", match, tt.wantMatch)
+			}
+
+			if next.ConversationID != "c1" || next.Flow != tt.wantFlow || next.State != tt.wantState {
+				t.Fatalf("Respond() next = %s %s/%s, want c1 %s/%s", next.ConversationID, next.Flow, next.State, tt.wantFlow, tt.wantState)
+			}
+
+			if len(next.Slots) != len(tt.wantSlots) || (len(tt.wantSlots) > 0 && !reflect.DeepEqual(next.Slots, tt.wantSlots)) {
+				t.Fatalf("Respond() slots = %v, want %v", next.Slots, tt.wantSlots)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"strings"
+	"unicode"
+
+	"message-service-kata/pkg/domain/entities"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+type (
+	// intentMatcher score message against rule patterns after normalizing both,
+	// so "hello", "Hello!" and "helo" can still match "Hello"
+	intentMatcher struct {
+		candidates []intentCandidate
+		threshold  float64
+	}
+
+	// intentCandidate is a rule pattern prepared for fuzzy matching
+	intentCandidate struct {
+		rule    *compiledRule
+		pattern string
+		tokens  map[string]struct{}
+	}
+)
+
+// folder is the unicode case folder used to normalize text
+var folder = cases.Fold()
+
+// newIntentMatcher initiating matcher for the given rules, only exact and case insensitive rules are
+// candidates since the other match types are already tolerant of the surrounding text
+func newIntentMatcher(rules []compiledRule, threshold float64) *intentMatcher {
+	m := &intentMatcher{threshold: threshold}
+
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Type != entities.MatchTypeExact && rule.Type != entities.MatchTypeCaseInsensitive {
+			continue
+		}
+
+		pattern := normalizeText(rule.Pattern)
+		m.candidates = append(m.candidates, intentCandidate{
+			rule:    rule,
+			pattern: pattern,
+			tokens:  tokenSet(pattern),
+		})
+	}
+
+	return m
+}
+
+// match return the best scoring rule when its score reach the threshold, rules keep their
+// evaluation order when they have the same score
+func (m *intentMatcher) match(message string) (rule *compiledRule, score float64) {
+	normalized := normalizeText(message)
+	if normalized == "" {
+		return nil, 0
+	}
+
+	tokens := tokenSet(normalized)
+	for _, candidate := range m.candidates {
+		candidateScore := similarity(normalized, tokens, candidate.pattern, candidate.tokens)
+		if candidateScore > score {
+			rule, score = candidate.rule, candidateScore
+		}
+	}
+
+	if score < m.threshold {
+		return nil, score
+	}
+
+	return rule, score
+}
+
+// normalizeText apply unicode compatibility decomposition, drop combining marks, fold case,
+// strip punctuation and symbols and collapse whitespace
+func normalizeText(text string) string {
+	text = folder.String(norm.NFKD.String(text))
+
+	var (
+		b     strings.Builder
+		space bool
+	)
+
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Mn, r), unicode.IsPunct(r), unicode.IsSymbol(r):
+			continue
+		case unicode.IsSpace(r):
+			space = true
+			continue
+		}
+
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// tokenSet split normalized text into set of words
+func tokenSet(text string) map[string]struct{} {
+	tokens := make(map[string]struct{})
+	for _, token := range strings.Fields(text) {
+		tokens[token] = struct{}{}
+	}
+
+	return tokens
+}
+
+// similarity score two normalized texts between 0 and 1 using the best of
+// edit distance similarity and token overlap (jaccard index)
+func similarity(a string, aTokens map[string]struct{}, b string, bTokens map[string]struct{}) float64 {
+	if a == b {
+		return 1
+	}
+
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+
+	editScore := 1 - float64(levenshtein(ra, rb))/float64(longest)
+
+	intersection := 0
+	for token := range aTokens {
+		if _, ok := bTokens[token]; ok {
+			intersection++
+		}
+	}
+
+	union := len(aTokens) + len(bTokens) - intersection
+	overlapScore := 0.0
+	if union > 0 {
+		overlapScore = float64(intersection) / float64(union)
+	}
+
+	if overlapScore > editScore {
+		return overlapScore
+	}
+
+	return editScore
+}
+
+// levenshtein return edit distance between two texts
+func levenshtein(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
+
+// minInt return the smallest of the given numbers
+func minInt(first int, rest ...int) int {
+	for _, n := range rest {
+		if n < first {
+			first = n
+		}
+	}
+
+	return first
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"math"
+	"testing"
+
+	"message-service-kata/pkg/domain/entities"
+)
+
+func TestNormalizeText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "empty", text: "", want: ""},
+		{name: "lower case", text: "Hello", want: "hello"},
+		{name: "punctuation", text: "Hello!!", want: "hello"},
+		{name: "symbols", text: "weather ☀ update 😊", want: "weather update"},
+		{name: "whitespace", text: "  Tell \t me\n a   joke  ", want: "tell me a joke"},
+		{name: "combining marks", text: "Café crème", want: "cafe creme"},
+		{name: "compatibility decomposition", text: "ｈｅｌｌｏ", want: "hello"},
+		{name: "case folding", text: "STRASSE Straße", want: "strasse strasse"},
+		{name: "only punctuation", text: "?!.", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeText(tt.text); got != tt.want {
+				t.Fatalf("normalizeText(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "", b: "", want: 0},
+		{a: "hello", b: "", want: 5},
+		{a: "", b: "hello", want: 5},
+		{a: "hello", b: "hello", want: 0},
+		{a: "hello", b: "helo", want: 1},
+		{a: "hello", b: "hallo", want: 1},
+		{a: "kitten", b: "sitting", want: 3},
+		{a: "flaw", b: "lawn", want: 2},
+		{a: "café", b: "cafe", want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			if got := levenshtein([]rune(tt.a), []rune(tt.b)); got != tt.want {
+				t.Fatalf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want float64
+	}{
+		{name: "equal", a: "hello", b: "hello", want: 1},
+		{name: "one typo", a: "helo", b: "hello", want: 0.8},
+		{name: "nothing in common", a: "abc", b: "xyz", want: 0},
+		{name: "token overlap beats edit distance", a: "joke tell me a", b: "tell me a joke", want: 1},
+		{name: "partial token overlap", a: "red car", b: "car blue", want: 1.0 / 3},
+		{name: "edit distance beats token overlap", a: "weather today", b: "weather update", want: 1 - 4.0/14},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := similarity(tt.a, tokenSet(tt.a), tt.b, tokenSet(tt.b))
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Fatalf("similarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntentMatcherMatch(t *testing.T) {
+	rules := []compiledRule{
+		{ResponseRule: entities.ResponseRule{Intent: "greeting", Type: entities.MatchTypeExact, Pattern: "Hello"}},
+		{ResponseRule: entities.ResponseRule{Intent: "joke", Type: entities.MatchTypeCaseInsensitive, Pattern: "Tell me a joke"}},
+		{ResponseRule: entities.ResponseRule{Intent: "price", Type: entities.MatchTypeContains, Pattern: "price"}},
+	}
+	matcher := newIntentMatcher(rules, 0.75)
+
+	tests := []struct {
+		name       string
+		message    string
+		wantIntent string
+	}{
+		{name: "punctuation and case", message: "hello!", wantIntent: "greeting"},
+		{name: "typo", message: "helo", wantIntent: "greeting"},
+		{name: "reordered words", message: "a joke, tell me", wantIntent: "joke"},
+		{name: "below threshold", message: "goodbye", wantIntent: ""},
+		{name: "contains rule is not a candidate", message: "price", wantIntent: ""},
+		{name: "empty after normalizing", message: "!!!", wantIntent: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, _ := matcher.match(tt.message)
+
+			intent := ""
+			if rule != nil {
+				intent = rule.Intent
+			}
+
+			if intent != tt.wantIntent {
+				t.Fatalf("match(%q) intent = %q, want %q", tt.message, intent, tt.wantIntent)
+			}
+		})
+	}
+}
@@ -3,15 +3,20 @@ package service
 //go:generate mockery --dir=$PROJECT_DIR/internal/app/service  --name=MessageSvc --filename=$GOFILE --output=$PROJECT_DIR/internal/generated/mock_service --outpkg=mock_service
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"sync"
+	"time"
 
+	"message-service-kata/internal/app/infra"
 	"message-service-kata/internal/app/repo/kafka"
 	"message-service-kata/internal/app/repo/postgres"
+	"message-service-kata/pkg/cerror"
 	"message-service-kata/pkg/domain/entities"
 
+	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 	"go.uber.org/dig"
 )
@@ -19,15 +24,26 @@ import (
 type (
 	// MessageSvc interfacing message service function
 	MessageSvc interface {
-		PostMessage(ctx context.Context, args *entities.CreateMessageRequest) (err error)
+		PostMessage(ctx context.Context, args *entities.CreateMessageRequest) (batch *entities.MessageBatch, err error)
 		ProcessMessage(ctx context.Context, args entities.MessageData) (err error)
+		ProcessCheckout(ctx context.Context, event entities.CheckoutEvent) (err error)
+		GetMessages(ctx context.Context, args *entities.ListMessageRequest) (messages []entities.ConsumedMessage, nextCursor int64, err error)
+		GetMessage(ctx context.Context, id int64) (message entities.ConsumedMessage, err error)
+		GetBatch(ctx context.Context, id int64) (batch entities.MessageBatch, err error)
+		GetConversation(ctx context.Context, args *entities.GetConversationRequest) (conversation entities.Conversation, err error)
 	}
 
 	// MessageSvcImpl implementing message service dependencies
 	MessageSvcImpl struct {
 		dig.In
-		MessageRepo postgres.MessageRepository
-		KafkaRepo   kafka.RepositoryKafka
+		MessageRepo      postgres.MessageRepository
+		BatchRepo        postgres.BatchRepository
+		ConversationRepo postgres.ConversationRepository
+		OutboxRepo       postgres.OutboxRepository
+		KafkaRepo        kafka.RepositoryKafka
+		KafkaCfg         *infra.KafkaCfg
+		ResponseEngine   ResponseEngine
+		DialogEngine     DialogEngine
 	}
 )
 
@@ -36,59 +52,163 @@ func NewMessageSvc(impl MessageSvcImpl) MessageSvc {
 	return &impl
 }
 
-// PostMessage service to produce message
+// PostMessage service to produce message, every call is tracked as a message batch
 func (s *MessageSvcImpl) PostMessage(
 	ctx context.Context, args *entities.CreateMessageRequest,
-) (err error) {
+) (batch *entities.MessageBatch, err error) {
 	log.Info().Msgf("[MessageSvc][PostMessage] incoming request with arg: %v", args)
 
-	// WaitGroup to wait for all goroutines to finish
-	var wg sync.WaitGroup
-
-	if args.Qty > 0 {
-		// Loop with qty on argument
-		for i := 0; i < int(args.Qty); i++ {
-			for j := 0; j < len(entities.Queries); j++ {
-				wg.Add(1)
-
-				// Passing `queries[j]` as a parameter to the goroutine to avoid closure issues
-				go func(query string) {
-					defer wg.Done()
-
-					// Recover from any panic inside the goroutine
-					defer func() {
-						if r := recover(); r != nil {
-							// Log the panic recovery
-							log.Printf("Panic recovered in goroutine: %v", r)
-						}
-					}()
-
-					// Assign value to model
-					message := entities.MessageData{
-						TriggerBy: args.TriggerBy,
-						Message:   query, // Use the passed query variable
-					}
-
-					// Publish message using Kafka
-					err := s.KafkaRepo.PublishWithoutKey(ctx, kafka.PublishData{
-						Topic: string(entities.TopicPublishMessage),
-						Data:  message,
-					})
-					if err != nil {
-						log.Error().Msgf("Error publishing message: %v", err)
-						return
-					}
-
-					log.Info().Msgf("[MessageSvc][PostMessage][PublishWithoutKey] success publish message with data: %v", message)
-				}(entities.Queries[j]) // Pass `queries[j]` as a parameter
-			}
+	// Messages to conversation of another user are rejected by the consumer, reject them before publishing
+	if args.ConversationID != "" {
+		err = s.ConversationRepo.CheckOwner(ctx, args.ConversationID, args.TriggerBy)
+		if err != nil {
+			log.Error().Msgf("[MessageSvc][PostMessage] error while CheckOwner conversation in postgre : %v", err)
+			return nil, err
+		}
+	}
+
+	// Use predefined queries when caller does not supply its own messages
+	items := args.Messages
+	if len(items) == 0 {
+		items = make([]entities.CreateMessageItem, 0, len(entities.Queries))
+		for _, query := range entities.Queries {
+			items = append(items, entities.CreateMessageItem{Message: query})
 		}
 	}
-	wg.Wait() // Wait for all goroutines to finish
+
+	batch = &entities.MessageBatch{
+		TriggerBy: args.TriggerBy,
+		Requested: args.Requested(),
+	}
+
+	// Messages are written to the outbox together with the request and published later by the outbox relay
+	if s.KafkaCfg.PublishOutbox {
+		return s.enqueueMessages(ctx, args, items, batch)
+	}
+
+	err = s.BatchRepo.Create(ctx, batch)
+	if err != nil {
+		log.Error().Msgf("[MessageSvc][PostMessage] error while Create batch in postgre : %v", err)
+		return nil, err
+	}
+
+	messages := make([]publishJob, 0, len(items))
+	for _, item := range items {
+		messages = append(messages, publishJob{
+			key:     messageKey(args, item),
+			message: newMessageData(args, item, batch.ID),
+		})
+	}
+
+	publisher := newBatchPublisher(s.KafkaRepo, s.KafkaCfg, batch)
+	if args.ConversationID != "" {
+		publisher.concurrency = 1
+	}
+	publisher.run(ctx, messages, args.Qty)
+
+	published, failed := publisher.published, publisher.failed
+
+	counters := entities.BatchCounters{Published: published, Failed: failed}
+	err = s.BatchRepo.IncrementCounters(ctx, batch.ID, counters)
+	if err != nil {
+		log.Error().Msgf("[MessageSvc][PostMessage] error while IncrementCounters batch in postgre : %v", err)
+		return nil, err
+	}
+
+	batch.Published = published
+	batch.Failed = failed
+	batch.Status = batch.ResolveStatus()
 
 	log.Info().Msgf("[MessageSvc][PostMessage] finish processing all message with arg: %v", args)
 
-	return nil
+	// Partial failure is reported through batch failures, the request only fails when
+	// the policy is fail fast or nothing could be published at all
+	if failed > 0 && (s.KafkaCfg.PublishPolicy == infra.PublishPolicyFailFast || published == 0) {
+		err = fmt.Errorf("%w: %d of %d messages failed", cerror.ErrPublishMessage, failed, batch.Requested)
+		if len(batch.Failures) > 0 {
+			err = fmt.Errorf("%w, first error: %s", err, batch.Failures[0].Error)
+		}
+
+		return batch, err
+	}
+
+	return batch, nil
+}
+
+// enqueueMessages store the batch, the request and qty times every message in the outbox within one transaction.
+// Published counter of the batch is incremented by the outbox relay once the messages are published.
+func (s *MessageSvcImpl) enqueueMessages(
+	ctx context.Context, args *entities.CreateMessageRequest, items []entities.CreateMessageItem, batch *entities.MessageBatch,
+) (*entities.MessageBatch, error) {
+	payload, err := json.Marshal(args)
+	if err != nil {
+		log.Error().Msgf("[MessageSvc][enqueueMessages] error while marshal request : %v", err)
+		return nil, err
+	}
+
+	request := &entities.MessageRequest{
+		TriggerBy:      args.TriggerBy,
+		ConversationID: args.ConversationID,
+		Payload:        payload,
+	}
+
+	err = s.OutboxRepo.CreateRequest(ctx, batch, request, func(
+		batch *entities.MessageBatch, _ *entities.MessageRequest, enqueue func(message entities.OutboxMessage) error,
+	) error {
+		for i := int64(0); i < args.Qty; i++ {
+			for _, item := range items {
+				data := newMessageData(args, item, batch.ID)
+				data.ID = uuid.NewString()
+
+				byt, err := json.Marshal(data)
+				if err != nil {
+					return err
+				}
+
+				err = enqueue(entities.OutboxMessage{
+					Topic:   string(entities.TopicPublishMessage),
+					Key:     messageKey(args, item),
+					Payload: byt,
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Error().Msgf("[MessageSvc][enqueueMessages] error while CreateRequest outbox in postgre : %v", err)
+		return nil, err
+	}
+
+	batch.Status = batch.ResolveStatus()
+
+	log.Info().Msgf("[MessageSvc][enqueueMessages] %d messages of request %d stored in outbox", batch.Requested, request.ID)
+
+	return batch, nil
+}
+
+// messageKey return kafka key of the posted message, messages of a conversation share its partition so they are
+// consumed in order
+func messageKey(args *entities.CreateMessageRequest, item entities.CreateMessageItem) string {
+	if args.ConversationID != "" {
+		return args.ConversationID
+	}
+
+	return item.Key
+}
+
+// newMessageData build the message data of the posted item
+func newMessageData(args *entities.CreateMessageRequest, item entities.CreateMessageItem, batchID int64) entities.MessageData {
+	return entities.MessageData{
+		ConversationID: args.ConversationID,
+		TriggerBy:      args.TriggerBy,
+		Message:        item.Message,
+		BatchID:        batchID,
+		Metadata:       item.Metadata,
+	}
 }
 
 // ProcessMessage service to process message
@@ -97,57 +217,337 @@ func (s *MessageSvcImpl) ProcessMessage(
 ) (err error) {
 	log.Info().Msgf("[MessageSvc][ProcessMessage] incoming request with arg: %v", args)
 
+	// Dialog state of the conversation is loaded and saved together with the message
+	if args.ConversationID != "" {
+		return s.processConversationMessage(ctx, args)
+	}
+
 	// Generate a response
-	responseMessage := generateResponse(args.Message)
-	log.Info().Msgf("[MessageSvc][ProcessMessage] reply request to : %v", responseMessage)
+	match := s.generateResponse(args)
+	log.Info().Msgf("[MessageSvc][ProcessMessage] reply request to : %v", match.Response)
 
 	// Prepare the JSON object for storage
-	data := map[string]interface{}{
-		"received_message": args.Message,
-		"response_message": responseMessage,
+	data := consumedMessageData(args, match, entities.DialogSession{})
+
+	// Storing failure is returned so the consumer retries the message instead of committing it
+	consumedMessageID, err := s.storeConsumedMessageAsJSON(ctx, args, data)
+	if err != nil {
+		return err
 	}
 
-	// Using go routine to make multithread processing
-	go func() {
-		err = s.storeConsumedMessageAsJSON(ctx, args.TriggerBy, data)
+	_ = s.publishReply(ctx, args, match, consumedMessageID)
+	s.countBatchMessage(ctx, args)
+
+	log.Info().Msgf("[MessageSvc][ProcessMessage] finish processing all message with data: %v", data)
+
+	return nil
+}
+
+// ProcessCheckout service to send templated message to the user of a checkout event. The message is stored and
+// published as reply of the checkout with checkout source type, so it reaches the streams of the user like a chatbot reply.
+func (s *MessageSvcImpl) ProcessCheckout(ctx context.Context, event entities.CheckoutEvent) (err error) {
+	log.Info().Msgf("[MessageSvc][ProcessCheckout] incoming request with arg: %v", event)
+
+	err = event.Validate()
+	if err != nil {
+		return cerror.Permanent(fmt.Errorf("invalid checkout event: %w", err))
+	}
+
+	message, err := renderCheckoutMessage(event)
+	if err != nil {
+		log.Error().Msgf("[MessageSvc][ProcessCheckout] error while render checkout message : %v", err)
+		return cerror.Permanent(err)
+	}
+
+	args := entities.MessageData{
+		ID:         fmt.Sprintf("%s-%s", entities.SourceTypeCheckout, event.OrderID),
+		TriggerBy:  event.UserID,
+		SourceType: entities.SourceTypeCheckout,
+		SourceID:   event.OrderID,
+		Metadata: map[string]string{
+			"event_id":     event.EventID,
+			"order_id":     event.OrderID,
+			"product_name": event.ProductName,
+			"product_type": event.ProductType,
+			"amount":       event.Amount.String(),
+			"currency":     event.Currency,
+		},
+	}
+	match := entities.ResponseMatch{
+		Intent:   fmt.Sprintf("%s_%s", entities.SourceTypeCheckout, event.Template()),
+		Score:    1,
+		Response: message,
+	}
+
+	// Redelivered, replayed or retried checkout is only stored once. Its reply is published again since the previous
+	// attempt may have failed before publishing it, the reply is keyed by the message ID so it stays idempotent.
+	consumedMessageID, err := s.storeConsumedMessageAsJSON(ctx, args, consumedMessageData(args, match, entities.DialogSession{}))
+	if errors.Is(err, cerror.ErrDuplicateMessage) {
+		log.Info().Msgf("[MessageSvc][ProcessCheckout] %s message of order %s already stored", event.Template(), event.OrderID)
+
+		consumedMessageID, err = s.MessageRepo.GetIDBySource(ctx, string(args.SourceType), args.SourceID)
 		if err != nil {
-			log.Error().Msgf("[MessageSvc][ProcessMessage] error while storeConsumedMessageAsJSON : %v", err)
+			log.Error().Msgf("[MessageSvc][ProcessCheckout] error while GetIDBySource %s in postgre : %v", args.ID, err)
+			return postgres.ClassifyError(err)
 		}
+	}
+	if err != nil {
+		return err
+	}
 
-		log.Info().Msgf("[MessageSvc][ProcessMessage] finish processing all message with data: %v", data)
-	}()
+	// Checkout message only reaches the user through its reply, so the event is retried until it is published
+	err = s.publishReply(ctx, args, match, consumedMessageID)
+	if err != nil {
+		return err
+	}
+
+	log.Info().Msgf("[MessageSvc][ProcessCheckout] send %s message of order %s to %s", event.Template(), event.OrderID, event.UserID)
+
+	return nil
+}
+
+// renderCheckoutMessage render the message template of the checkout
+func renderCheckoutMessage(event entities.CheckoutEvent) (string, error) {
+	name := event.Template()
+
+	tmpl, err := parseResponseTemplate(name, entities.DefaultCheckoutTemplates[name])
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, entities.CheckoutTemplateData{
+		CheckoutEvent: event,
+		Now:           entities.TemplateTime{Time: time.Now()},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// processConversationMessage respond to message within the dialog of its conversation, the dialog state is
+// loaded and saved in the same transaction as the consumed message
+func (s *MessageSvcImpl) processConversationMessage(ctx context.Context, args entities.MessageData) (err error) {
+	var match entities.ResponseMatch
+
+	message := &entities.MessageData{
+		ConversationID: args.ConversationID,
+		TriggerBy:      args.TriggerBy,
+	}
+
+	consumedMessageID, err := s.MessageRepo.CreateWithDialog(ctx, message,
+		func(session entities.DialogSession) (string, entities.DialogSession, error) {
+			var (
+				next    entities.DialogSession
+				handled bool
+			)
+
+			match, next, handled = s.DialogEngine.Respond(args, session)
+			if !handled {
+				match = s.generateResponse(args)
+			}
+
+			jsonData, err := json.Marshal(consumedMessageData(args, match, next))
+			if err != nil {
+				return "", next, cerror.Permanent(fmt.Errorf("failed to marshal data to JSON: %w", err))
+			}
+
+			return string(jsonData), next, nil
+		},
+	)
+	if err != nil {
+		log.Error().Msgf("[MessageSvc][ProcessMessage] error while CreateWithDialog in postgre : %v", err)
+		return postgres.ClassifyError(err)
+	}
+
+	log.Info().Msgf("[MessageSvc][ProcessMessage] reply conversation %s to : %v", args.ConversationID, match.Response)
+
+	_ = s.publishReply(ctx, args, match, consumedMessageID)
+	s.countBatchMessage(ctx, args)
+
+	return nil
+}
+
+// GetMessages service to list consumed messages using cursor pagination
+func (s *MessageSvcImpl) GetMessages(
+	ctx context.Context, args *entities.ListMessageRequest,
+) (messages []entities.ConsumedMessage, nextCursor int64, err error) {
+	if args.Limit <= 0 {
+		args.Limit = entities.DefaultListLimit
+	}
+
+	// Fetch one extra row to know whether there is a next page
+	limit := args.Limit
+	args.Limit++
+	defer func() { args.Limit = limit }()
+
+	messages, err = s.MessageRepo.List(ctx, args)
+	if err != nil {
+		log.Error().Msgf("[MessageSvc][GetMessages] error while List Data in postgre : %v", err)
+		return nil, 0, err
+	}
+
+	if len(messages) > limit {
+		messages = messages[:limit]
+		nextCursor = messages[limit-1].ID
+	}
+
+	return messages, nextCursor, nil
+}
+
+// GetMessage service to get consumed message by id
+func (s *MessageSvcImpl) GetMessage(ctx context.Context, id int64) (message entities.ConsumedMessage, err error) {
+	message, err = s.MessageRepo.GetByID(ctx, id)
+	if err != nil {
+		log.Error().Msgf("[MessageSvc][GetMessage] error while GetByID Data in postgre : %v", err)
+		return message, err
+	}
+
+	return message, nil
+}
+
+// GetBatch service to get message batch progress by id
+func (s *MessageSvcImpl) GetBatch(ctx context.Context, id int64) (batch entities.MessageBatch, err error) {
+	batch, err = s.BatchRepo.GetByID(ctx, id)
+	if err != nil {
+		log.Error().Msgf("[MessageSvc][GetBatch] error while GetByID batch in postgre : %v", err)
+		return batch, err
+	}
+
+	return batch, nil
+}
+
+// GetConversation service to get conversation of the user with its full transcript
+func (s *MessageSvcImpl) GetConversation(
+	ctx context.Context, args *entities.GetConversationRequest,
+) (conversation entities.Conversation, err error) {
+	conversation, err = s.ConversationRepo.GetByID(ctx, args.ID, args.TriggerBy)
+	if err != nil {
+		log.Error().Msgf("[MessageSvc][GetConversation] error while GetByID conversation in postgre : %v", err)
+		return conversation, err
+	}
+
+	conversation.Messages, err = s.MessageRepo.ListByConversation(ctx, args.ID)
+	if err != nil {
+		log.Error().Msgf("[MessageSvc][GetConversation] error while ListByConversation in postgre : %v", err)
+		return conversation, err
+	}
+
+	return conversation, nil
+}
+
+// countBatchMessage count stored message in its batch progress once, failure is only logged since the
+// message is already stored
+func (s *MessageSvcImpl) countBatchMessage(ctx context.Context, args entities.MessageData) {
+	if args.BatchID == 0 {
+		return
+	}
+
+	err := s.BatchRepo.CountMessage(ctx, args.BatchID, args.ID)
+	if err != nil {
+		log.Error().Msgf("[MessageSvc][ProcessMessage] error while CountMessage %s of batch %d : %v", args.ID, args.BatchID, err)
+	}
+}
+
+// publishReply produce the chatbot reply keyed by the message ID so downstream services can react to it. A chatbot
+// reply is stored in postgre as well, so its failure is only logged there, while a checkout is retried on failure.
+func (s *MessageSvcImpl) publishReply(
+	ctx context.Context, args entities.MessageData, match entities.ResponseMatch, consumedMessageID int64,
+) (err error) {
+	if s.KafkaCfg.ReplyTopic == "" {
+		return nil
+	}
+
+	err = s.KafkaRepo.PublishWithKey(ctx, kafka.PublishData{
+		Topic: s.KafkaCfg.ReplyTopic,
+		Key:   args.ID,
+		Data: entities.MessageReplyEvent{
+			CorrelationID:     args.ID,
+			ConsumedMessageID: consumedMessageID,
+			SessionID:         args.SessionID,
+			ConversationID:    args.ConversationID,
+			TriggerBy:         args.TriggerBy,
+			BatchID:           args.BatchID,
+			Message:           args.Message,
+			Response:          match.Response,
+			Intent:            match.Intent,
+			IntentScore:       match.Score,
+			Metadata:          args.Metadata,
+			RepliedAt:         time.Now(),
+			SourceType:        args.SourceType,
+		},
+	})
+	if err != nil {
+		log.Error().Msgf("[MessageSvc][ProcessMessage] error while publish reply of message %s : %v", args.ID, err)
+		return fmt.Errorf("publish reply of message %s: %w", args.ID, err)
+	}
+
+	log.Info().Msgf("[MessageSvc][ProcessMessage] success publish reply of message %s to %s", args.ID, s.KafkaCfg.ReplyTopic)
 
 	return nil
 }
 
 // generateResponse generates a response based on the received message
-func generateResponse(message string) string {
-	if response, found := entities.Responses[message]; found {
-		return response
+func (s *MessageSvcImpl) generateResponse(args entities.MessageData) entities.ResponseMatch {
+	return s.ResponseEngine.Respond(args)
+}
+
+// consumedMessageData prepare the JSON object of consumed message, the dialog is only set while it is active
+func consumedMessageData(
+	args entities.MessageData, match entities.ResponseMatch, session entities.DialogSession,
+) map[string]interface{} {
+	data := map[string]interface{}{
+		"received_message": args.Message,
+		"response_message": match.Response,
+	}
+	if match.Intent != "" {
+		data["intent"] = match.Intent
+		data["intent_score"] = match.Score
+	}
+	if session.Flow != "" {
+		data["dialog"] = map[string]interface{}{
+			"flow":  session.Flow,
+			"state": session.State,
+			"slots": session.Slots,
+		}
+	}
+	if len(args.Metadata) > 0 {
+		data["metadata"] = args.Metadata
 	}
-	return string(entities.FallbackResponse)
+
+	return data
 }
 
 // storeConsumedMessageAsJSON saves the received message and response to PostgreSQL as JSONB
-func (s *MessageSvcImpl) storeConsumedMessageAsJSON(ctx context.Context, triggerBy string, data map[string]interface{}) (err error) {
+func (s *MessageSvcImpl) storeConsumedMessageAsJSON(
+	ctx context.Context, args entities.MessageData, data map[string]interface{},
+) (messageID int64, err error) {
 	// Convert the map to JSON
 	jsonData, err := json.Marshal(data)
 	if err != nil {
 		err = fmt.Errorf("failed to marshal data to JSON: %w", err)
 		log.Error().Msgf("[MessageSvc][ProcessMessage] error while Marshal jsonData : %v", err)
-		return err
+		return 0, cerror.Permanent(err)
 	}
 
 	message := &entities.MessageData{
-		TriggerBy: triggerBy,
-		Message:   string(jsonData),
+		ConversationID: args.ConversationID,
+		TriggerBy:      args.TriggerBy,
+		Message:        string(jsonData),
+		SourceType:     args.SourceType,
+		SourceID:       args.SourceID,
 	}
 
-	_, err = s.MessageRepo.Create(ctx, message)
+	messageID, err = s.MessageRepo.Create(ctx, message)
+	if errors.Is(err, cerror.ErrDuplicateMessage) {
+		return 0, err
+	}
 	if err != nil {
 		log.Error().Msgf("[MessageSvc][ProcessMessage] error while Create Data in postgre : %v", err)
-		return err
+		return 0, postgres.ClassifyError(err)
 	}
 
-	return nil
+	return messageID, nil
 }
@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"message-service-kata/internal/app/infra"
+	"message-service-kata/internal/app/repo/kafka"
+	"message-service-kata/internal/app/repo/postgres"
+	"message-service-kata/pkg/cerror"
+	"message-service-kata/pkg/domain/entities"
+
+	"github.com/lib/pq"
+)
+
+type (
+	// fakeKafkaRepo record delivered data, fail return the produce and delivery error of the data. overlapped is set
+	// when a keyed message is produced while an earlier message of its key waits for its delivery report.
+	fakeKafkaRepo struct {
+		kafka.RepositoryKafka
+		fail func(data kafka.PublishData) (produceErr, deliveryErr error)
+
+		mu         sync.Mutex
+		delivered  []kafka.PublishData
+		inFlight   map[string]int
+		overlapped bool
+	}
+
+	// fakeMessageRepo return the configured results of the consumed message queries
+	fakeMessageRepo struct {
+		postgres.MessageRepository
+		createID    int64
+		createErr   error
+		sourceID    int64
+		sourceIDErr error
+	}
+)
+
+func (f *fakeKafkaRepo) errors(data kafka.PublishData) (produceErr, deliveryErr error) {
+	if f.fail == nil {
+		return nil, nil
+	}
+
+	return f.fail(data)
+}
+
+func (f *fakeKafkaRepo) record(data kafka.PublishData, deliveryErr error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if deliveryErr == nil {
+		f.delivered = append(f.delivered, data)
+	}
+}
+
+func (f *fakeKafkaRepo) PublishWithKey(ctx context.Context, args kafka.PublishData) error {
+	produceErr, deliveryErr := f.errors(args)
+	if produceErr != nil {
+		return produceErr
+	}
+
+	f.record(args, deliveryErr)
+
+	return deliveryErr
+}
+
+func (f *fakeKafkaRepo) PublishWithoutKey(ctx context.Context, args kafka.PublishData) error {
+	return f.PublishWithKey(ctx, args)
+}
+
+func (f *fakeKafkaRepo) PublishAsync(ctx context.Context, args kafka.PublishData, onDelivery infra.DeliveryHandler) error {
+	produceErr, deliveryErr := f.errors(args)
+	if produceErr != nil {
+		return produceErr
+	}
+
+	f.record(args, deliveryErr)
+
+	f.mu.Lock()
+	if f.inFlight == nil {
+		f.inFlight = make(map[string]int)
+	}
+	if args.Key != "" && f.inFlight[args.Key] > 0 {
+		f.overlapped = true
+	}
+	f.inFlight[args.Key]++
+	f.mu.Unlock()
+
+	go func() {
+		f.mu.Lock()
+		f.inFlight[args.Key]--
+		f.mu.Unlock()
+
+		onDelivery(deliveryErr)
+	}()
+
+	return nil
+}
+
+func (f *fakeMessageRepo) Create(ctx context.Context, args *entities.MessageData) (int64, error) {
+	return f.createID, f.createErr
+}
+
+func (f *fakeMessageRepo) GetIDBySource(ctx context.Context, sourceType, sourceID string) (int64, error) {
+	return f.sourceID, f.sourceIDErr
+}
+
+func TestRenderCheckoutMessage(t *testing.T) {
+	tests := []struct {
+		name  string
+		event entities.CheckoutEvent
+		want  string
+	}{
+		{
+			name: "first purchase",
+			event: entities.CheckoutEvent{
+				OrderID: "ORD-1", UserName: "Budi", ProductName: "Gold Fund", Amount: "1500000", Currency: "IDR", FirstPurchase: true,
+			},
+			want: "Congratulations Budi! 🎉 Your first investment in Gold Fund of IDR 1500000 is confirmed. Welcome aboard!",
+		},
+		{
+			name: "later purchase",
+			event: entities.CheckoutEvent{
+				OrderID: "ORD-2", UserName: "Budi", ProductName: "Gold Fund", Amount: "250000.50", Currency: "IDR",
+			},
+			want: "Hi Budi, your checkout of Gold Fund of IDR 250000.50 is confirmed. ✅ Order ORD-2",
+		},
+		{
+			name:  "without user name",
+			event: entities.CheckoutEvent{OrderID: "ORD-3", ProductName: "Bond", Amount: "10", Currency: "USD"},
+			want:  "Hi, your checkout of Bond of USD 10 is confirmed. ✅ Order ORD-3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderCheckoutMessage(tt.event)
+			if err != nil {
+				t.Fatalf("renderCheckoutMessage() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Fatalf("renderCheckoutMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessageSvcProcessCheckout(t *testing.T) {
+	event := entities.CheckoutEvent{OrderID: "ORD-1", UserID: "user-1", ProductName: "Gold Fund", Amount: "100", Currency: "IDR"}
+	brokerDown := errors.New("broker down")
+
+	tests := []struct {
+		name          string
+		event         entities.CheckoutEvent
+		replyTopic    string
+		messageRepo   *fakeMessageRepo
+		publishErr    error
+		wantErr       error
+		wantClass     cerror.ErrorClass
+		wantPublished int64
+	}{
+		{
+			name:          "new checkout publishes its reply",
+			event:         event,
+			replyTopic:    "message.reply",
+			messageRepo:   &fakeMessageRepo{createID: 7},
+			wantPublished: 7,
+		},
+		{
+			name:          "duplicate checkout publishes the reply of the stored message again",
+			event:         event,
+			replyTopic:    "message.reply",
+			messageRepo:   &fakeMessageRepo{createErr: cerror.ErrDuplicateMessage, sourceID: 5},
+			wantPublished: 5,
+		},
+		{
+			name:        "duplicate checkout without stored message",
+			event:       event,
+			replyTopic:  "message.reply",
+			messageRepo: &fakeMessageRepo{createErr: cerror.ErrDuplicateMessage, sourceIDErr: &pq.Error{Code: "08006"}},
+			wantClass:   cerror.ClassTransientInfra,
+		},
+		{
+			name:        "reply which can not be published fails the event",
+			event:       event,
+			replyTopic:  "message.reply",
+			messageRepo: &fakeMessageRepo{createID: 7},
+			publishErr:  brokerDown,
+			wantErr:     brokerDown,
+			wantClass:   cerror.ClassRetryable,
+		},
+		{
+			name:        "store failure",
+			event:       event,
+			replyTopic:  "message.reply",
+			messageRepo: &fakeMessageRepo{createErr: &pq.Error{Code: "23503"}},
+			wantClass:   cerror.ClassPermanent,
+		},
+		{
+			name:        "invalid event",
+			event:       entities.CheckoutEvent{OrderID: "ORD-1"},
+			replyTopic:  "message.reply",
+			messageRepo: &fakeMessageRepo{createID: 7},
+			wantClass:   cerror.ClassPermanent,
+		},
+		{
+			name:        "reply topic disabled",
+			event:       event,
+			messageRepo: &fakeMessageRepo{createID: 7},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kafkaRepo := &fakeKafkaRepo{fail: func(data kafka.PublishData) (error, error) {
+				return nil, tt.publishErr
+			}}
+			svc := &MessageSvcImpl{
+				MessageRepo: tt.messageRepo,
+				KafkaRepo:   kafkaRepo,
+				KafkaCfg:    &infra.KafkaCfg{ReplyTopic: tt.replyTopic},
+			}
+
+			err := svc.ProcessCheckout(context.Background(), tt.event)
+			if tt.wantClass == "" {
+				if err != nil {
+					t.Fatalf("ProcessCheckout() error = %v", err)
+				}
+			} else {
+				if err == nil || cerror.ClassOf(err) != tt.wantClass {
+					t.Fatalf("ProcessCheckout() error = %v class %s, want class %s", err, cerror.ClassOf(err), tt.wantClass)
+				}
+
+				if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ProcessCheckout() error = %v, want %v", err, tt.wantErr)
+				}
+			}
+
+			if tt.wantPublished == 0 {
+				if len(kafkaRepo.delivered) != 0 {
+					t.Fatalf("ProcessCheckout() published %d replies, want none", len(kafkaRepo.delivered))
+				}
+				return
+			}
+
+			if len(kafkaRepo.delivered) != 1 {
+				t.Fatalf("ProcessCheckout() published %d replies, want 1", len(kafkaRepo.delivered))
+			}
+
+			published := kafkaRepo.delivered[0]
+			reply, ok := published.Data.(entities.MessageReplyEvent)
+			if !ok {
+				t.Fatalf("ProcessCheckout() published %T, want entities.MessageReplyEvent", published.Data)
+			}
+
+			if published.Topic != "message.reply" || published.Key != "checkout-ORD-1" {
+				t.Fatalf("ProcessCheckout() published to %s with key %s", published.Topic, published.Key)
+			}
+
+			if reply.ConsumedMessageID != tt.wantPublished || reply.TriggerBy != "user-1" ||
+				!strings.Contains(reply.Response, "Gold Fund") {
+				t.Fatalf("ProcessCheckout() reply = %+v", reply)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package service
+
+//go:generate mockery --dir=$PROJECT_DIR/internal/app/service  --name=OutboxSvc --filename=$GOFILE --output=$PROJECT_DIR/internal/generated/mock_service --outpkg=mock_service
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"message-service-kata/internal/app/infra"
+	"message-service-kata/internal/app/repo/kafka"
+	"message-service-kata/internal/app/repo/postgres"
+	"message-service-kata/pkg/cerror"
+	"message-service-kata/pkg/domain/entities"
+
+	"github.com/rs/zerolog/log"
+	"go.uber.org/dig"
+)
+
+// outboxFailureStreak is the number of consecutive failed messages after which a relay round stops, the broker
+// is likely unavailable and the rest of the round is left for the next one
+const outboxFailureStreak = 3
+
+type (
+	// OutboxSvc interfacing outbox service function
+	OutboxSvc interface {
+		RelayOutbox(ctx context.Context) (result entities.OutboxRelayResult, err error)
+	}
+
+	// OutboxSvcImpl implementing outbox service dependencies
+	OutboxSvcImpl struct {
+		dig.In
+		OutboxRepo postgres.OutboxRepository
+		KafkaRepo  kafka.RepositoryKafka
+		KafkaCfg   *infra.KafkaCfg
+	}
+)
+
+// NewOutboxSvc initiating outbox service
+func NewOutboxSvc(impl OutboxSvcImpl) OutboxSvc {
+	return &impl
+}
+
+// RelayOutbox service to publish one batch of the oldest pending outbox messages and mark the delivered ones as sent.
+// Failed message stays pending with its error and is published again after a backoff, it is parked as failed once
+// it reached the max attempts. Only the later messages of its key wait for it.
+func (s *OutboxSvcImpl) RelayOutbox(ctx context.Context) (result entities.OutboxRelayResult, err error) {
+	args := entities.OutboxRelayRequest{
+		Limit:           s.KafkaCfg.OutboxBatchSize,
+		MaxAttempts:     s.KafkaCfg.OutboxMaxAttempts,
+		RetryBackoff:    s.KafkaCfg.OutboxRetryBackoff,
+		RetryBackoffMax: s.KafkaCfg.OutboxRetryBackoffMax,
+	}
+
+	result, err = s.OutboxRepo.Relay(ctx, args, func(messages []entities.OutboxMessage) []error {
+		if s.KafkaCfg.PublishAsync {
+			return s.publishOutboxAsync(ctx, messages)
+		}
+
+		return s.publishOutbox(ctx, messages)
+	})
+	if err != nil {
+		log.Error().Msgf("[OutboxSvc][RelayOutbox] error while Relay outbox in postgre : %v", err)
+		return result, err
+	}
+
+	if result.Failed > 0 {
+		log.Error().Msgf(
+			"[OutboxSvc][RelayOutbox] %d of %d outbox messages failed to be published, %d parked and %d held back",
+			result.Failed, result.Claimed, result.Parked, result.HeldBack,
+		)
+	}
+
+	return result, nil
+}
+
+// publishOutbox publish outbox messages one by one. Messages behind a failed message of their key are held back,
+// so the messages of a key are never published out of order, and the round stops after a streak of failures.
+func (s *OutboxSvcImpl) publishOutbox(ctx context.Context, messages []entities.OutboxMessage) (errs []error) {
+	errs = make([]error, 0, len(messages))
+	failedKeys := make(map[string]bool)
+	streak := 0
+	for _, message := range messages {
+		if message.Key != "" && failedKeys[message.Key] {
+			errs = append(errs, cerror.ErrOutboxHeldBack)
+			continue
+		}
+
+		data := outboxPublishData(message)
+
+		var err error
+		if data.Key != "" {
+			err = s.KafkaRepo.PublishWithKey(ctx, data)
+		} else {
+			err = s.KafkaRepo.PublishWithoutKey(ctx, data)
+		}
+
+		errs = append(errs, err)
+		if err == nil {
+			streak = 0
+			continue
+		}
+
+		log.Error().Msgf("[OutboxSvc][publishOutbox] error while publish outbox message %d : %v", message.ID, err)
+		failedKeys[message.Key] = true
+
+		streak++
+		if streak >= outboxFailureStreak {
+			break
+		}
+	}
+
+	return errs
+}
+
+// publishOutboxAsync publish outbox messages in waves without waiting for each delivery report. A wave produces the
+// next message of every key and every keyless message, then waits for their delivery, so a message is only produced
+// once the previous message of its key is delivered. Messages behind a failed message of their key are held back, and
+// the round stops after a streak of produce failures or a wave without any delivered message.
+func (s *OutboxSvcImpl) publishOutboxAsync(ctx context.Context, messages []entities.OutboxMessage) (errs []error) {
+	errs = make([]error, len(messages))
+
+	waiting := make([]int, len(messages))
+	for i := range waiting {
+		waiting[i] = i
+	}
+
+	for len(waiting) > 0 {
+		wave, later := outboxWave(messages, waiting)
+
+		stopped := s.produceOutboxWave(ctx, messages, wave, errs)
+
+		failedKeys := make(map[string]bool)
+		for _, i := range wave {
+			if errs[i] != nil {
+				failedKeys[messages[i].Key] = true
+			}
+		}
+
+		waiting = waiting[:0]
+		for _, i := range later {
+			if stopped || failedKeys[messages[i].Key] {
+				errs[i] = cerror.ErrOutboxHeldBack
+				continue
+			}
+
+			waiting = append(waiting, i)
+		}
+	}
+
+	for i, err := range errs {
+		if err != nil && !errors.Is(err, cerror.ErrOutboxHeldBack) {
+			log.Error().Msgf("[OutboxSvc][publishOutboxAsync] error while publish outbox message %d : %v", messages[i].ID, err)
+		}
+	}
+
+	return errs
+}
+
+// outboxWave split the waiting messages into the next message of every key and every keyless message, and the later
+// messages of the keys
+func outboxWave(messages []entities.OutboxMessage, waiting []int) (wave, later []int) {
+	keys := make(map[string]bool)
+	for _, i := range waiting {
+		key := messages[i].Key
+		if key != "" && keys[key] {
+			later = append(later, i)
+			continue
+		}
+
+		keys[key] = true
+		wave = append(wave, i)
+	}
+
+	return wave, later
+}
+
+// produceOutboxWave produce the wave messages and wait for their delivery, errs[i] is set to the error of messages[i].
+// It returns true when the round has to stop, the wave messages left after a streak of produce failures are held back.
+func (s *OutboxSvcImpl) produceOutboxWave(
+	ctx context.Context, messages []entities.OutboxMessage, wave []int, errs []error,
+) (stopped bool) {
+	streak := 0
+
+	var inFlight sync.WaitGroup
+	for _, i := range wave {
+		i := i
+
+		if stopped {
+			errs[i] = cerror.ErrOutboxHeldBack
+			continue
+		}
+
+		inFlight.Add(1)
+		err := s.KafkaRepo.PublishAsync(ctx, outboxPublishData(messages[i]), func(err error) {
+			defer inFlight.Done()
+			errs[i] = err
+		})
+		if err != nil {
+			inFlight.Done()
+			errs[i] = err
+
+			streak++
+			stopped = streak >= outboxFailureStreak
+			continue
+		}
+		streak = 0
+	}
+	inFlight.Wait()
+
+	if stopped {
+		return true
+	}
+
+	failed := 0
+	for _, i := range wave {
+		if errs[i] == nil {
+			return false
+		}
+		failed++
+	}
+
+	return failed >= outboxFailureStreak
+}
+
+// outboxPublishData build kafka publish data of the outbox message, the stored payload is published as it is
+func outboxPublishData(message entities.OutboxMessage) kafka.PublishData {
+	return kafka.PublishData{
+		Topic: message.Topic,
+		Key:   message.Key,
+		Data:  message.Payload,
+	}
+}
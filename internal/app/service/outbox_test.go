@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"message-service-kata/internal/app/repo/kafka"
+	"message-service-kata/pkg/cerror"
+	"message-service-kata/pkg/domain/entities"
+)
+
+// outboxFailure is how the fake producer fails an outbox message
+type outboxFailure int
+
+const (
+	outboxProduceFails outboxFailure = iota + 1
+	outboxDeliveryFails
+)
+
+// outboxMessages build outbox messages with the given keys, the payload is the message ID
+func outboxMessages(keys ...string) []entities.OutboxMessage {
+	messages := make([]entities.OutboxMessage, 0, len(keys))
+	for i, key := range keys {
+		id := int64(i + 1)
+		messages = append(messages, entities.OutboxMessage{
+			ID:      id,
+			Topic:   "message.publish",
+			Key:     key,
+			Payload: json.RawMessage(strconv.FormatInt(id, 10)),
+		})
+	}
+
+	return messages
+}
+
+// outboxResults summarize the errors of the published outbox messages as ok, held or failed
+func outboxResults(errs []error) []string {
+	results := make([]string, 0, len(errs))
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			results = append(results, "ok")
+		case errors.Is(err, cerror.ErrOutboxHeldBack):
+			results = append(results, "held")
+		default:
+			results = append(results, "failed")
+		}
+	}
+
+	return results
+}
+
+// newOutboxKafkaRepo return fake producer failing the outbox messages by ID
+func newOutboxKafkaRepo(failures map[int64]outboxFailure) *fakeKafkaRepo {
+	return &fakeKafkaRepo{fail: func(data kafka.PublishData) (error, error) {
+		id, _ := strconv.ParseInt(string(data.Data.(json.RawMessage)), 10, 64)
+
+		switch failures[id] {
+		case outboxProduceFails:
+			return errors.New("queue full"), nil
+		case outboxDeliveryFails:
+			return nil, errors.New("message timed out")
+		}
+
+		return nil, nil
+	}}
+}
+
+// deliveredIDs return the IDs of the delivered outbox messages of key in delivery order
+func deliveredIDs(repo *fakeKafkaRepo, key string) []int64 {
+	var ids []int64
+	for _, data := range repo.delivered {
+		if data.Key == key {
+			id, _ := strconv.ParseInt(string(data.Data.(json.RawMessage)), 10, 64)
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+var outboxPublishTests = []struct {
+	name      string
+	keys      []string
+	failures  map[int64]outboxFailure
+	wantSync  []string
+	wantAsync []string
+}{
+	{
+		name:      "every message delivered",
+		keys:      []string{"a", "b", "a", "", "a"},
+		wantSync:  []string{"ok", "ok", "ok", "ok", "ok"},
+		wantAsync: []string{"ok", "ok", "ok", "ok", "ok"},
+	},
+	{
+		name:      "failed produce holds back the later messages of its key",
+		keys:      []string{"a", "b", "a", "b", "a"},
+		failures:  map[int64]outboxFailure{1: outboxProduceFails},
+		wantSync:  []string{"failed", "ok", "held", "ok", "held"},
+		wantAsync: []string{"failed", "ok", "held", "ok", "held"},
+	},
+	{
+		name:      "failed delivery holds back the later messages of its key",
+		keys:      []string{"a", "b", "a", "b", "a"},
+		failures:  map[int64]outboxFailure{3: outboxDeliveryFails},
+		wantSync:  []string{"ok", "ok", "failed", "ok", "held"},
+		wantAsync: []string{"ok", "ok", "failed", "ok", "held"},
+	},
+	{
+		name:      "failed keyless message holds back nothing",
+		keys:      []string{"", "", "a"},
+		failures:  map[int64]outboxFailure{1: outboxDeliveryFails},
+		wantSync:  []string{"failed", "ok", "ok"},
+		wantAsync: []string{"failed", "ok", "ok"},
+	},
+	{
+		name:      "streak of failed produces stops the round",
+		keys:      []string{"a", "b", "c", "d", "a"},
+		failures:  map[int64]outboxFailure{1: outboxProduceFails, 2: outboxProduceFails, 3: outboxProduceFails},
+		wantSync:  []string{"failed", "failed", "failed"},
+		wantAsync: []string{"failed", "failed", "failed", "held", "held"},
+	},
+	{
+		name:      "wave without delivered message stops the round",
+		keys:      []string{"a", "b", "c", "a", "d"},
+		failures:  map[int64]outboxFailure{1: outboxDeliveryFails, 2: outboxDeliveryFails, 3: outboxDeliveryFails, 5: outboxDeliveryFails},
+		wantSync:  []string{"failed", "failed", "failed"},
+		wantAsync: []string{"failed", "failed", "failed", "held", "failed"},
+	},
+}
+
+func TestOutboxSvcPublishOutbox(t *testing.T) {
+	for _, tt := range outboxPublishTests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newOutboxKafkaRepo(tt.failures)
+			svc := &OutboxSvcImpl{KafkaRepo: repo}
+
+			errs := svc.publishOutbox(context.Background(), outboxMessages(tt.keys...))
+			if got := outboxResults(errs); !reflect.DeepEqual(got, tt.wantSync) {
+				t.Fatalf("publishOutbox() = %v, want %v", got, tt.wantSync)
+			}
+
+			if ids := deliveredIDs(repo, "a"); !sortedIDs(ids) {
+				t.Fatalf("publishOutbox() delivered key a as %v", ids)
+			}
+		})
+	}
+}
+
+func TestOutboxSvcPublishOutboxAsync(t *testing.T) {
+	for _, tt := range outboxPublishTests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newOutboxKafkaRepo(tt.failures)
+			svc := &OutboxSvcImpl{KafkaRepo: repo}
+
+			errs := svc.publishOutboxAsync(context.Background(), outboxMessages(tt.keys...))
+			if got := outboxResults(errs); !reflect.DeepEqual(got, tt.wantAsync) {
+				t.Fatalf("publishOutboxAsync() = %v, want %v", got, tt.wantAsync)
+			}
+
+			if repo.overlapped {
+				t.Fatalf("publishOutboxAsync() produced a message before the previous message of its key was delivered")
+			}
+
+			if ids := deliveredIDs(repo, "a"); !sortedIDs(ids) {
+				t.Fatalf("publishOutboxAsync() delivered key a as %v", ids)
+			}
+		})
+	}
+}
+
+func TestOutboxWave(t *testing.T) {
+	tests := []struct {
+		name      string
+		keys      []string
+		waiting   []int
+		wantWave  []int
+		wantLater []int
+	}{
+		{
+			name:     "distinct keys",
+			keys:     []string{"a", "b", "c"},
+			waiting:  []int{0, 1, 2},
+			wantWave: []int{0, 1, 2},
+		},
+		{
+			name:      "first message of every key",
+			keys:      []string{"a", "b", "a", "b", "a"},
+			waiting:   []int{0, 1, 2, 3, 4},
+			wantWave:  []int{0, 1},
+			wantLater: []int{2, 3, 4},
+		},
+		{
+			name:     "every keyless message",
+			keys:     []string{"", "a", "", ""},
+			waiting:  []int{0, 1, 2, 3},
+			wantWave: []int{0, 1, 2, 3},
+		},
+		{
+			name:      "only waiting messages",
+			keys:      []string{"a", "b", "a", "b", "a"},
+			waiting:   []int{2, 3, 4},
+			wantWave:  []int{2, 3},
+			wantLater: []int{4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wave, later := outboxWave(outboxMessages(tt.keys...), tt.waiting)
+			if !reflect.DeepEqual(wave, tt.wantWave) || !reflect.DeepEqual(later, tt.wantLater) {
+				t.Fatalf("outboxWave() = %v, %v, want %v, %v", wave, later, tt.wantWave, tt.wantLater)
+			}
+		})
+	}
+}
+
+// sortedIDs report whether ids are in ascending order
+func sortedIDs(ids []int64) bool {
+	for i := 1; i < len(ids); i++ {
+		if ids[i] < ids[i-1] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"message-service-kata/internal/app/infra"
+	"message-service-kata/internal/app/repo/kafka"
+	"message-service-kata/pkg/domain/entities"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+type (
+	// publishJob is a single message of a batch waiting to be published
+	publishJob struct {
+		index   int
+		key     string
+		message entities.MessageData
+	}
+
+	// batchPublisher publish messages of a batch using a bounded worker pool
+	batchPublisher struct {
+		kafkaRepo kafka.RepositoryKafka
+		cfg       *infra.KafkaCfg
+		batch     *entities.MessageBatch
+		cancel    context.CancelFunc
+
+		// concurrency is the number of publish workers, a single worker publish messages in order
+		concurrency int
+
+		// inFlight track asynchronously produced messages waiting for delivery report
+		inFlight    sync.WaitGroup
+		inFlightSem chan struct{}
+
+		mu        sync.Mutex
+		published int64
+		failed    int64
+	}
+)
+
+// newBatchPublisher initiating publisher for the given batch
+func newBatchPublisher(kafkaRepo kafka.RepositoryKafka, cfg *infra.KafkaCfg, batch *entities.MessageBatch) *batchPublisher {
+	return &batchPublisher{
+		kafkaRepo:   kafkaRepo,
+		cfg:         cfg,
+		batch:       batch,
+		concurrency: cfg.PublishConcurrency,
+		inFlightSem: make(chan struct{}, cfg.PublishMaxInFlight),
+	}
+}
+
+// run publish every message qty times and wait until all of them are delivered or failed
+func (p *batchPublisher) run(ctx context.Context, messages []publishJob, qty int64) {
+	// Fail fast policy cancel the messages which are not published yet on the first failure
+	ctx, p.cancel = context.WithCancel(ctx)
+	defer p.cancel()
+
+	jobs := make(chan publishJob)
+
+	var workers sync.WaitGroup
+	for w := 0; w < p.concurrency; w++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for job := range jobs {
+				p.publish(ctx, job)
+			}
+		}()
+	}
+
+	index := 0
+	for i := int64(0); i < qty; i++ {
+		for _, job := range messages {
+			job.index = index
+			job.message.ID = uuid.NewString()
+			jobs <- job
+			index++
+		}
+	}
+	close(jobs)
+
+	workers.Wait()
+	p.inFlight.Wait()
+
+	sort.Slice(p.batch.Failures, func(i, j int) bool {
+		return p.batch.Failures[i].Index < p.batch.Failures[j].Index
+	})
+}
+
+// publish publish a single message, synchronously or asynchronously depending on config
+func (p *batchPublisher) publish(ctx context.Context, job publishJob) {
+	// Recover from any panic while publishing
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Panic recovered in publish worker: %v", r)
+			p.record(job, publishMode(job.key, p.cfg.PublishAsync), fmt.Errorf("panic: %v", r))
+		}
+	}()
+
+	// Skipped messages are counted as failed without being listed as failure
+	if ctx.Err() != nil {
+		p.skip()
+		return
+	}
+
+	data := kafka.PublishData{
+		Topic: string(entities.TopicPublishMessage),
+		Key:   job.key,
+		Data:  job.message,
+	}
+
+	if !p.cfg.PublishAsync {
+		if data.Key != "" {
+			p.record(job, "PublishWithKey", p.kafkaRepo.PublishWithKey(ctx, data))
+			return
+		}
+
+		p.record(job, "PublishWithoutKey", p.kafkaRepo.PublishWithoutKey(ctx, data))
+		return
+	}
+
+	select {
+	case p.inFlightSem <- struct{}{}:
+	case <-ctx.Done():
+		p.skip()
+		return
+	}
+
+	p.inFlight.Add(1)
+	done := func() {
+		<-p.inFlightSem
+		p.inFlight.Done()
+	}
+
+	err := p.kafkaRepo.PublishAsync(ctx, data, func(err error) {
+		defer done()
+		p.record(job, "PublishAsync", err)
+	})
+	if err != nil {
+		done()
+		p.record(job, "PublishAsync", err)
+	}
+}
+
+// publishMode name of the kafka repository function a message is published with
+func publishMode(key string, async bool) string {
+	switch {
+	case async:
+		return "PublishAsync"
+	case key != "":
+		return "PublishWithKey"
+	default:
+		return "PublishWithoutKey"
+	}
+}
+
+// record update batch result of a message published with the given mode
+func (p *batchPublisher) record(job publishJob, mode string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err == nil {
+		p.published++
+		log.Info().Msgf("[MessageSvc][PostMessage][%s] success publish message with data: %v", mode, job.message)
+		return
+	}
+
+	log.Error().Msgf("[MessageSvc][PostMessage][%s] error publishing message: %v", mode, err)
+
+	p.failed++
+	p.batch.Failures = append(p.batch.Failures, entities.PublishFailure{
+		Index:   job.index,
+		Message: job.message.Message,
+		Error:   err.Error(),
+	})
+
+	if p.cfg.PublishPolicy == infra.PublishPolicyFailFast {
+		p.cancel()
+	}
+}
+
+// skip count message which is not published because the batch is cancelled
+func (p *batchPublisher) skip() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.failed++
+}
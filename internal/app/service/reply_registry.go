@@ -0,0 +1,134 @@
+package service
+
+//go:generate mockery --dir=$PROJECT_DIR/internal/app/service  --name=ReplyRegistry --filename=$GOFILE --output=$PROJECT_DIR/internal/generated/mock_service --outpkg=mock_service
+
+import (
+	"sync"
+
+	"message-service-kata/pkg/domain/entities"
+
+	"github.com/rs/zerolog/log"
+)
+
+type (
+	// ReplyRegistry interfacing correlation registry of requests waiting for chatbot reply
+	// and broadcaster of every reply to the live subscribers
+	ReplyRegistry interface {
+		Register(correlationID string) (replyCh <-chan entities.MessageReplyEvent, unregister func())
+		Subscribe(buffer int) (replyCh <-chan entities.MessageReplyEvent, unsubscribe func())
+		RegisterSession(sessionID string, buffer int) (replyCh <-chan entities.MessageReplyEvent, unregister func())
+		Dispatch(reply entities.MessageReplyEvent) (delivered bool)
+	}
+
+	// replyRegistry implementing in-memory correlation registry of the running instance
+	replyRegistry struct {
+		mu          sync.Mutex
+		waiters     map[string]chan entities.MessageReplyEvent
+		subscribers map[chan entities.MessageReplyEvent]struct{}
+		sessions    map[string]chan entities.MessageReplyEvent
+	}
+)
+
+// NewReplyRegistry initiating reply registry
+func NewReplyRegistry() ReplyRegistry {
+	return &replyRegistry{
+		waiters:     make(map[string]chan entities.MessageReplyEvent),
+		subscribers: make(map[chan entities.MessageReplyEvent]struct{}),
+		sessions:    make(map[string]chan entities.MessageReplyEvent),
+	}
+}
+
+// Register wait for reply of the correlation ID, unregister must be called once the reply is no longer awaited
+func (r *replyRegistry) Register(correlationID string) (replyCh <-chan entities.MessageReplyEvent, unregister func()) {
+	ch := make(chan entities.MessageReplyEvent, 1)
+
+	r.mu.Lock()
+	r.waiters[correlationID] = ch
+	r.mu.Unlock()
+
+	return ch, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		if r.waiters[correlationID] == ch {
+			delete(r.waiters, correlationID)
+		}
+	}
+}
+
+// Subscribe receive every dispatched reply. A subscriber which falls more than buffer replies behind
+// has its channel closed, so it can resume from the stored messages instead of blocking the others.
+func (r *replyRegistry) Subscribe(buffer int) (replyCh <-chan entities.MessageReplyEvent, unsubscribe func()) {
+	ch := make(chan entities.MessageReplyEvent, buffer)
+
+	r.mu.Lock()
+	r.subscribers[ch] = struct{}{}
+	r.mu.Unlock()
+
+	return ch, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		if _, ok := r.subscribers[ch]; ok {
+			delete(r.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// RegisterSession receive replies of every message sent by the session until unregister is called
+func (r *replyRegistry) RegisterSession(
+	sessionID string, buffer int,
+) (replyCh <-chan entities.MessageReplyEvent, unregister func()) {
+	ch := make(chan entities.MessageReplyEvent, buffer)
+
+	r.mu.Lock()
+	r.sessions[sessionID] = ch
+	r.mu.Unlock()
+
+	return ch, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		if r.sessions[sessionID] == ch {
+			delete(r.sessions, sessionID)
+		}
+	}
+}
+
+// Dispatch broadcast reply to the subscribers and hand it over to its waiter, replies which nobody waits
+// for are not delivered since they belong to other instances or to requests that already timed out
+func (r *replyRegistry) Dispatch(reply entities.MessageReplyEvent) (delivered bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for sub := range r.subscribers {
+		select {
+		case sub <- reply:
+		default:
+			delete(r.subscribers, sub)
+			close(sub)
+		}
+	}
+
+	// Session which can not keep up loses the reply instead of blocking the reply consumer
+	if ch, ok := r.sessions[reply.SessionID]; ok && reply.SessionID != "" {
+		select {
+		case ch <- reply:
+			return true
+		default:
+			log.Warn().Msgf("[ReplyRegistry] session %s is full, drop reply of message %s", reply.SessionID, reply.CorrelationID)
+			return false
+		}
+	}
+
+	ch, ok := r.waiters[reply.CorrelationID]
+	if !ok {
+		return false
+	}
+	delete(r.waiters, reply.CorrelationID)
+
+	ch <- reply
+
+	return true
+}
@@ -0,0 +1,168 @@
+package service
+
+//go:generate mockery --dir=$PROJECT_DIR/internal/app/service  --name=ResponseSvc --filename=$GOFILE --output=$PROJECT_DIR/internal/generated/mock_service --outpkg=mock_service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"message-service-kata/internal/app/repo/kafka"
+	"message-service-kata/internal/app/repo/postgres"
+	"message-service-kata/pkg/cerror"
+	"message-service-kata/pkg/domain/entities"
+
+	"github.com/rs/zerolog/log"
+	"go.uber.org/dig"
+)
+
+const (
+	// responseActionCreate invalidation action when chatbot response is created
+	responseActionCreate = "create"
+	// responseActionUpdate invalidation action when chatbot response is updated
+	responseActionUpdate = "update"
+	// responseActionDelete invalidation action when chatbot response is deleted
+	responseActionDelete = "delete"
+)
+
+type (
+	// ResponseSvc interfacing chatbot response service function
+	ResponseSvc interface {
+		ListResponses(ctx context.Context) (responses []entities.ChatbotResponse, err error)
+		CreateResponse(ctx context.Context, args *entities.UpsertResponseRequest) (response entities.ChatbotResponse, err error)
+		UpdateResponse(ctx context.Context, args *entities.UpsertResponseRequest) (response entities.ChatbotResponse, err error)
+		DeleteResponse(ctx context.Context, id int64) (err error)
+		RefreshResponses(ctx context.Context) (err error)
+		CatalogVersion() string
+	}
+
+	// ResponseSvcImpl implementing chatbot response service dependencies
+	ResponseSvcImpl struct {
+		dig.In
+		ResponseRepo    postgres.ResponseRepository
+		KafkaRepo       kafka.RepositoryKafka
+		ResponseCatalog ResponseCatalog
+	}
+)
+
+// NewResponseSvc initiating chatbot response service
+func NewResponseSvc(impl ResponseSvcImpl) ResponseSvc {
+	return &impl
+}
+
+// ListResponses service to list chatbot responses in evaluation order
+func (s *ResponseSvcImpl) ListResponses(ctx context.Context) (responses []entities.ChatbotResponse, err error) {
+	responses, err = s.ResponseRepo.List(ctx)
+	if err != nil {
+		log.Error().Msgf("[ResponseSvc][ListResponses] error while List Data in postgre : %v", err)
+		return nil, err
+	}
+
+	return responses, nil
+}
+
+// CreateResponse service to create chatbot response
+func (s *ResponseSvcImpl) CreateResponse(
+	ctx context.Context, args *entities.UpsertResponseRequest,
+) (response entities.ChatbotResponse, err error) {
+	response, err = newChatbotResponse(args)
+	if err != nil {
+		return response, err
+	}
+
+	err = s.ResponseRepo.Create(ctx, &response)
+	if err != nil {
+		log.Error().Msgf("[ResponseSvc][CreateResponse] error while Create Data in postgre : %v", err)
+		return response, err
+	}
+
+	s.publishInvalidation(ctx, response.ID, responseActionCreate)
+
+	return response, nil
+}
+
+// UpdateResponse service to update chatbot response
+func (s *ResponseSvcImpl) UpdateResponse(
+	ctx context.Context, args *entities.UpsertResponseRequest,
+) (response entities.ChatbotResponse, err error) {
+	response, err = newChatbotResponse(args)
+	if err != nil {
+		return response, err
+	}
+
+	err = s.ResponseRepo.Update(ctx, &response)
+	if err != nil {
+		log.Error().Msgf("[ResponseSvc][UpdateResponse] error while Update Data in postgre : %v", err)
+		return response, err
+	}
+
+	s.publishInvalidation(ctx, response.ID, responseActionUpdate)
+
+	return response, nil
+}
+
+// DeleteResponse service to delete chatbot response
+func (s *ResponseSvcImpl) DeleteResponse(ctx context.Context, id int64) (err error) {
+	err = s.ResponseRepo.Delete(ctx, id)
+	if err != nil {
+		log.Error().Msgf("[ResponseSvc][DeleteResponse] error while Delete Data in postgre : %v", err)
+		return err
+	}
+
+	s.publishInvalidation(ctx, id, responseActionDelete)
+
+	return nil
+}
+
+// RefreshResponses service to reload cached chatbot responses
+func (s *ResponseSvcImpl) RefreshResponses(ctx context.Context) (err error) {
+	err = s.ResponseCatalog.Refresh(ctx)
+	if err != nil {
+		log.Error().Msgf("[ResponseSvc][RefreshResponses] error while Refresh catalog : %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// CatalogVersion service to get version of the cached chatbot responses
+func (s *ResponseSvcImpl) CatalogVersion() string {
+	return s.ResponseCatalog.Version()
+}
+
+// newChatbotResponse build chatbot response from request and make sure it can be used by response engine
+func newChatbotResponse(args *entities.UpsertResponseRequest) (response entities.ChatbotResponse, err error) {
+	response = entities.ChatbotResponse{
+		ID: args.ID,
+		ResponseRule: entities.ResponseRule{
+			Intent:   args.Intent,
+			Type:     args.Type,
+			Pattern:  args.Pattern,
+			Response: args.Response,
+			Priority: args.Priority,
+		},
+	}
+
+	_, err = compileRule(response.ResponseRule)
+	if err != nil {
+		return response, fmt.Errorf("%w: %v", cerror.ErrInvalidResponseRule, err)
+	}
+
+	return response, nil
+}
+
+// publishInvalidation notify consumers to refresh their cached chatbot responses, failure is only
+// logged since the consumers also refresh periodically
+func (s *ResponseSvcImpl) publishInvalidation(ctx context.Context, responseID int64, action string) {
+	err := s.KafkaRepo.PublishWithoutKey(ctx, kafka.PublishData{
+		Topic: string(entities.TopicResponseInvalidate),
+		Data: entities.ResponseInvalidateEvent{
+			ResponseID:    responseID,
+			Action:        action,
+			InvalidatedAt: time.Now(),
+		},
+	})
+	if err != nil {
+		log.Error().Msgf("[ResponseSvc] error while publish invalidation of response %d : %v", responseID, err)
+	}
+}
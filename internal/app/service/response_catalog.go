@@ -0,0 +1,252 @@
+package service
+
+//go:generate mockery --dir=$PROJECT_DIR/internal/app/service  --name=ResponseCatalog --filename=$GOFILE --output=$PROJECT_DIR/internal/generated/mock_service --outpkg=mock_service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"message-service-kata/internal/app/infra"
+	"message-service-kata/internal/app/repo/postgres"
+	"message-service-kata/pkg/cerror"
+	"message-service-kata/pkg/domain/entities"
+
+	"github.com/rs/zerolog/log"
+	"go.uber.org/dig"
+	"gopkg.in/yaml.v3"
+)
+
+// responseRefreshTimeout is the timeout of loading chatbot responses on refresh
+const responseRefreshTimeout = 10 * time.Second
+
+type (
+	// ResponseCatalog interfacing cached chatbot response catalog function
+	ResponseCatalog interface {
+		ResponseEngine
+		Refresh(ctx context.Context) (err error)
+		Run(shutdownCh <-chan struct{})
+		Version() string
+	}
+
+	// ResponseCatalogImpl implementing read through in-memory cache of chatbot responses. Responses are loaded
+	// from the file configured by APP_RESPONSES_FILE, or from database when no file is configured.
+	ResponseCatalogImpl struct {
+		dig.In `ignore-unexported:"true"`
+
+		ResponseRepo postgres.ResponseRepository
+		AppCfg       *infra.AppCfg
+
+		// state holds the *catalogState built from the last successful refresh
+		state atomic.Value
+	}
+
+	// catalogState is the response engine of a catalog version
+	catalogState struct {
+		engine   ResponseEngine
+		version  string
+		checksum string
+	}
+)
+
+// NewResponseCatalog initiating response catalog, the predefined responses are used
+// until chatbot responses are loaded. Invalid responses file fails the initiation.
+func NewResponseCatalog(impl ResponseCatalogImpl) (ResponseCatalog, error) {
+	c := &impl
+
+	engine, err := NewResponseEngine(c.withMatchThreshold(entities.DefaultResponseRules))
+	if err != nil {
+		return nil, err
+	}
+	c.state.Store(&catalogState{engine: engine, version: "default"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), responseRefreshTimeout)
+	defer cancel()
+
+	err = c.Refresh(ctx)
+	if err != nil && c.AppCfg.ResponsesFile != "" {
+		return nil, fmt.Errorf("load responses file %s: %w", c.AppCfg.ResponsesFile, err)
+	}
+	if err != nil {
+		log.Error().Msgf("[ResponseCatalog] error while Refresh, using predefined responses : %v", err)
+	}
+
+	return c, nil
+}
+
+// NewCatalogResponseEngine provide response catalog as the response engine used to generate reply
+func NewCatalogResponseEngine(catalog ResponseCatalog) ResponseEngine {
+	return catalog
+}
+
+// Respond choose response using the cached response engine
+func (c *ResponseCatalogImpl) Respond(args entities.MessageData) entities.ResponseMatch {
+	return c.current().engine.Respond(args)
+}
+
+// Version return version of the cached chatbot responses
+func (c *ResponseCatalogImpl) Version() string {
+	return c.current().version
+}
+
+// Refresh reload chatbot responses and swap the cached engine atomically when they are changed. The previous
+// engine is kept when loading fails or the responses are invalid, so a bad update is rolled back.
+func (c *ResponseCatalogImpl) Refresh(ctx context.Context) (err error) {
+	ruleSet, err := c.load(ctx)
+	if err != nil {
+		return err
+	}
+	ruleSet = c.withMatchThreshold(ruleSet)
+
+	checksum, err := ruleSetChecksum(ruleSet)
+	if err != nil {
+		return err
+	}
+
+	previous := c.current()
+	if checksum == previous.checksum {
+		return nil
+	}
+
+	engine, err := NewResponseEngine(ruleSet)
+	if err != nil {
+		return fmt.Errorf("%w: %v", cerror.ErrInvalidResponseRule, err)
+	}
+
+	version := ruleSet.Version
+	if version == "" {
+		version = checksum
+	}
+	c.state.Store(&catalogState{engine: engine, version: version, checksum: checksum})
+
+	log.Info().Msgf("[ResponseCatalog] swapped version %s to %s with %d chatbot responses",
+		previous.version, version, len(ruleSet.Rules))
+
+	return nil
+}
+
+// Run refresh the catalog periodically until shutdown, periodic refresh is disabled when interval is not positive
+func (c *ResponseCatalogImpl) Run(shutdownCh <-chan struct{}) {
+	interval := c.AppCfg.ResponsesRefreshInterval
+	if c.AppCfg.ResponsesFile != "" {
+		interval = c.AppCfg.ResponsesWatchInterval
+	}
+
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-shutdownCh:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), responseRefreshTimeout)
+			err := c.Refresh(ctx)
+			cancel()
+
+			if err != nil {
+				log.Error().Msgf("[ResponseCatalog] error while Refresh, keep version %s : %v", c.Version(), err)
+			}
+		}
+	}
+}
+
+// current return the cached catalog state
+func (c *ResponseCatalogImpl) current() *catalogState {
+	return c.state.Load().(*catalogState)
+}
+
+// withMatchThreshold apply the configured fuzzy match threshold when rule set does not define its own
+func (c *ResponseCatalogImpl) withMatchThreshold(ruleSet entities.ResponseRuleSet) entities.ResponseRuleSet {
+	if ruleSet.MatchThreshold == 0 {
+		ruleSet.MatchThreshold = c.AppCfg.ResponseMatchThreshold
+	}
+
+	return ruleSet
+}
+
+// load read chatbot responses rule set from the configured source
+func (c *ResponseCatalogImpl) load(ctx context.Context) (ruleSet entities.ResponseRuleSet, err error) {
+	if c.AppCfg.ResponsesFile != "" {
+		return loadResponseFile(c.AppCfg.ResponsesFile)
+	}
+
+	responses, err := c.ResponseRepo.List(ctx)
+	if err != nil {
+		return ruleSet, err
+	}
+
+	// Predefined responses are used while there is no chatbot response in database
+	if len(responses) == 0 {
+		return entities.DefaultResponseRules, nil
+	}
+
+	ruleSet = entities.ResponseRuleSet{
+		Rules:            make([]entities.ResponseRule, 0, len(responses)),
+		FallbackResponse: entities.FallbackResponse,
+	}
+	for _, response := range responses {
+		ruleSet.Rules = append(ruleSet.Rules, response.ResponseRule)
+	}
+
+	return ruleSet, nil
+}
+
+// loadResponseFile read chatbot responses rule set from YAML or JSON file, unknown fields are rejected
+func loadResponseFile(path string) (ruleSet entities.ResponseRuleSet, err error) {
+	err = decodeConfigFile(path, &ruleSet)
+	if err != nil {
+		return ruleSet, fmt.Errorf("%w: %v", cerror.ErrInvalidResponseRule, err)
+	}
+
+	if len(ruleSet.Rules) == 0 {
+		return ruleSet, fmt.Errorf("%w: responses file has no rules", cerror.ErrInvalidResponseRule)
+	}
+
+	return ruleSet, nil
+}
+
+// decodeConfigFile decode YAML or JSON file chosen by its extension into out, unknown fields are rejected
+func decodeConfigFile(path string, out interface{}) (err error) {
+	byt, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(byt))
+		decoder.KnownFields(true)
+		return decoder.Decode(out)
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(byt))
+		decoder.DisallowUnknownFields()
+		return decoder.Decode(out)
+	default:
+		return fmt.Errorf("unsupported file extension: %s", filepath.Ext(path))
+	}
+}
+
+// ruleSetChecksum return short checksum of rule set content used to detect changes
+func ruleSetChecksum(ruleSet entities.ResponseRuleSet) (string, error) {
+	byt, err := json.Marshal(ruleSet)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(byt)
+
+	return hex.EncodeToString(sum[:6]), nil
+}
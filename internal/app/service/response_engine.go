@@ -0,0 +1,235 @@
+package service
+
+//go:generate mockery --dir=$PROJECT_DIR/internal/app/service  --name=ResponseEngine --filename=$GOFILE --output=$PROJECT_DIR/internal/generated/mock_service --outpkg=mock_service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"message-service-kata/pkg/domain/entities"
+
+	"github.com/rs/zerolog/log"
+)
+
+type (
+	// ResponseEngine interfacing chatbot response engine function
+	ResponseEngine interface {
+		Respond(args entities.MessageData) entities.ResponseMatch
+	}
+
+	// ruleEngine implementing response engine using ordered rules
+	ruleEngine struct {
+		rules            []compiledRule
+		fallbackResponse *template.Template
+		// matcher is nil when fuzzy intent matching is disabled
+		matcher *intentMatcher
+	}
+
+	// compiledRule is a response rule prepared for matching
+	compiledRule struct {
+		entities.ResponseRule
+		pattern  string
+		regex    *regexp.Regexp
+		template *template.Template
+	}
+)
+
+// NewResponseEngine initiating response engine from rule set, rules are evaluated by
+// priority descending and keep their order within the same priority
+func NewResponseEngine(ruleSet entities.ResponseRuleSet) (ResponseEngine, error) {
+	if ruleSet.MatchThreshold < 0 || ruleSet.MatchThreshold > 1 {
+		return nil, fmt.Errorf("match threshold must be between 0 and 1: %v", ruleSet.MatchThreshold)
+	}
+
+	fallbackResponse := ruleSet.FallbackResponse
+	if fallbackResponse == "" {
+		fallbackResponse = entities.FallbackResponse
+	}
+
+	fallbackTemplate, err := parseResponseTemplate("fallback", fallbackResponse)
+	if err != nil {
+		return nil, fmt.Errorf("fallback response: %w", err)
+	}
+
+	engine := &ruleEngine{
+		rules:            make([]compiledRule, 0, len(ruleSet.Rules)),
+		fallbackResponse: fallbackTemplate,
+	}
+
+	for i, rule := range ruleSet.Rules {
+		compiled, err := compileRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d (%s): %w", i, rule.Intent, err)
+		}
+
+		engine.rules = append(engine.rules, compiled)
+	}
+
+	sort.SliceStable(engine.rules, func(i, j int) bool {
+		return engine.rules[i].Priority > engine.rules[j].Priority
+	})
+
+	if ruleSet.MatchThreshold > 0 {
+		engine.matcher = newIntentMatcher(engine.rules, ruleSet.MatchThreshold)
+	}
+
+	return engine, nil
+}
+
+// NewDefaultResponseEngine initiating response engine using predefined chatbot responses
+func NewDefaultResponseEngine() (ResponseEngine, error) {
+	return NewResponseEngine(entities.DefaultResponseRules)
+}
+
+// Respond choose response of the first matching rule, or the closest intent when no rule matched
+// and fuzzy matching is enabled, otherwise the fallback response and render it
+func (e *ruleEngine) Respond(args entities.MessageData) entities.ResponseMatch {
+	message := strings.TrimSpace(args.Message)
+
+	data := entities.ResponseTemplateData{
+		TriggerBy: args.TriggerBy,
+		Message:   args.Message,
+		Now:       entities.TemplateTime{Time: time.Now()},
+		Metadata:  args.Metadata,
+	}
+
+	for _, rule := range e.rules {
+		matched, groups, namedGroups := rule.match(message)
+		if !matched {
+			continue
+		}
+
+		data.Groups = groups
+		data.NamedGroups = namedGroups
+
+		response, err := renderResponse(rule.template, data)
+		if err != nil {
+			log.Error().Msgf("[ResponseEngine] error while render response of intent %s : %v", rule.Intent, err)
+			break
+		}
+
+		return entities.ResponseMatch{
+			Intent:   rule.Intent,
+			Score:    1,
+			Response: response,
+		}
+	}
+
+	data.Groups, data.NamedGroups = nil, nil
+
+	if e.matcher != nil {
+		rule, score := e.matcher.match(message)
+		if rule != nil {
+			response, err := renderResponse(rule.template, data)
+			if err == nil {
+				return entities.ResponseMatch{
+					Intent:   rule.Intent,
+					Score:    score,
+					Response: response,
+				}
+			}
+
+			log.Error().Msgf("[ResponseEngine] error while render response of intent %s : %v", rule.Intent, err)
+		}
+	}
+
+	response, err := renderResponse(e.fallbackResponse, data)
+	if err != nil {
+		log.Error().Msgf("[ResponseEngine] error while render fallback response : %v", err)
+		response = entities.FallbackResponse
+	}
+
+	return entities.ResponseMatch{Response: response}
+}
+
+// compileRule validate rule and prepare its pattern for matching
+func compileRule(rule entities.ResponseRule) (compiled compiledRule, err error) {
+	compiled.ResponseRule = rule
+
+	if rule.Pattern == "" {
+		return compiled, errors.New("pattern is required")
+	}
+
+	if rule.Response == "" {
+		return compiled, errors.New("response is required")
+	}
+
+	switch rule.Type {
+	case entities.MatchTypeExact:
+		compiled.pattern = rule.Pattern
+	case entities.MatchTypeCaseInsensitive, entities.MatchTypeContains, entities.MatchTypePrefix:
+		compiled.pattern = strings.ToLower(rule.Pattern)
+	case entities.MatchTypeRegex:
+		compiled.regex, err = regexp.Compile(rule.Pattern)
+		if err != nil {
+			return compiled, fmt.Errorf("invalid regex pattern: %w", err)
+		}
+	default:
+		return compiled, fmt.Errorf("unknown match type: %s", rule.Type)
+	}
+
+	compiled.template, err = parseResponseTemplate(rule.Intent, rule.Response)
+	if err != nil {
+		return compiled, err
+	}
+
+	return compiled, nil
+}
+
+// match check whether message matches the rule, regex rule also return its captured groups
+func (r *compiledRule) match(message string) (matched bool, groups []string, namedGroups map[string]string) {
+	switch r.Type {
+	case entities.MatchTypeExact:
+		return message == r.pattern, nil, nil
+	case entities.MatchTypeCaseInsensitive:
+		return strings.EqualFold(message, r.pattern), nil, nil
+	case entities.MatchTypeRegex:
+		groups = r.regex.FindStringSubmatch(message)
+		if groups == nil {
+			return false, nil, nil
+		}
+
+		namedGroups = make(map[string]string)
+		for i, name := range r.regex.SubexpNames() {
+			if name != "" {
+				namedGroups[name] = groups[i]
+			}
+		}
+
+		return true, groups, namedGroups
+	case entities.MatchTypeContains:
+		return strings.Contains(strings.ToLower(message), r.pattern), nil, nil
+	case entities.MatchTypePrefix:
+		return strings.HasPrefix(strings.ToLower(message), r.pattern), nil, nil
+	}
+
+	return false, nil, nil
+}
+
+// parseResponseTemplate parse response as text template, missing map key is rendered as empty string
+func parseResponseTemplate(name, response string) (*template.Template, error) {
+	tmpl, err := template.New(name).Option("missingkey=zero").Parse(response)
+	if err != nil {
+		return nil, fmt.Errorf("invalid response template: %w", err)
+	}
+
+	return tmpl, nil
+}
+
+// renderResponse execute response template with message context
+func renderResponse(tmpl *template.Template, data entities.ResponseTemplateData) (string, error) {
+	var buf bytes.Buffer
+
+	err := tmpl.Execute(&buf, data)
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,317 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"message-service-kata/pkg/domain/entities"
+)
+
+func TestNewResponseEngine(t *testing.T) {
+	tests := []struct {
+		name    string
+		ruleSet entities.ResponseRuleSet
+		wantErr string
+	}{
+		{
+			name:    "default rules",
+			ruleSet: entities.DefaultResponseRules,
+		},
+		{
+			name: "missing pattern",
+			ruleSet: entities.ResponseRuleSet{Rules: []entities.ResponseRule{
+				{Intent: "greeting", Type: entities.MatchTypeExact, Response: "Hi"},
+			}},
+			wantErr: "rule 0 (greeting): pattern is required",
+		},
+		{
+			name: "missing response",
+			ruleSet: entities.ResponseRuleSet{Rules: []entities.ResponseRule{
+				{Intent: "greeting", Type: entities.MatchTypeExact, Pattern: "Hello"},
+			}},
+			wantErr: "rule 0 (greeting): response is required",
+		},
+		{
+			name: "unknown match type",
+			ruleSet: entities.ResponseRuleSet{Rules: []entities.ResponseRule{
+				{Intent: "greeting", Type: "fuzzy", Pattern: "Hello", Response: "Hi"},
+			}},
+			wantErr: "rule 0 (greeting): unknown match type: fuzzy",
+		},
+		{
+			name: "invalid regex",
+			ruleSet: entities.ResponseRuleSet{Rules: []entities.ResponseRule{
+				{Intent: "greeting", Type: entities.MatchTypeRegex, Pattern: "(hello", Response: "Hi"},
+			}},
+			wantErr: "rule 0 (greeting): invalid regex pattern",
+		},
+		{
+			name:    "match threshold above 1",
+			ruleSet: entities.ResponseRuleSet{MatchThreshold: 1.5},
+			wantErr: "match threshold must be between 0 and 1",
+		},
+		{
+			name:    "negative match threshold",
+			ruleSet: entities.ResponseRuleSet{MatchThreshold: -0.1},
+			wantErr: "match threshold must be between 0 and 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewResponseEngine(tt.ruleSet)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("NewResponseEngine() error = %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("NewResponseEngine() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRuleEngineRespond(t *testing.T) {
+	engine, err := NewResponseEngine(entities.ResponseRuleSet{
+		FallbackResponse: "fallback",
+		Rules: []entities.ResponseRule{
+			{Intent: "exact", Type: entities.MatchTypeExact, Pattern: "Hello", Response: "exact"},
+			{Intent: "case_insensitive", Type: entities.MatchTypeCaseInsensitive, Pattern: "Good Morning", Response: "case insensitive"},
+			{Intent: "contains", Type: entities.MatchTypeContains, Pattern: "price", Response: "contains"},
+			{Intent: "prefix", Type: entities.MatchTypePrefix, Pattern: "help", Response: "prefix"},
+			{Intent: "regex", Type: entities.MatchTypeRegex, Pattern: `^order \d+$`, Response: "regex"},
+			{Intent: "low_priority", Type: entities.MatchTypeContains, Pattern: "refund", Response: "low priority"},
+			{Intent: "high_priority", Type: entities.MatchTypeContains, Pattern: "refund", Response: "high priority", Priority: 10},
+			{Intent: "first_of_same_priority", Type: entities.MatchTypeContains, Pattern: "ticket", Response: "first"},
+			{Intent: "second_of_same_priority", Type: entities.MatchTypeContains, Pattern: "ticket", Response: "second"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewResponseEngine() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		message string
+		want    entities.ResponseMatch
+	}{
+		{
+			name:    "exact",
+			message: "Hello",
+			want:    entities.ResponseMatch{Intent: "exact", Score: 1, Response: "exact"},
+		},
+		{
+			name:    "exact ignores surrounding whitespace",
+			message: "  Hello\n",
+			want:    entities.ResponseMatch{Intent: "exact", Score: 1, Response: "exact"},
+		},
+		{
+			name:    "exact is case sensitive",
+			message: "hello",
+			want:    entities.ResponseMatch{Response: "fallback"},
+		},
+		{
+			name:    "case insensitive",
+			message: "GOOD MORNING",
+			want:    entities.ResponseMatch{Intent: "case_insensitive", Score: 1, Response: "case insensitive"},
+		},
+		{
+			name:    "contains",
+			message: "What is the PRICE today",
+			want:    entities.ResponseMatch{Intent: "contains", Score: 1, Response: "contains"},
+		},
+		{
+			name:    "prefix",
+			message: "Help me please",
+			want:    entities.ResponseMatch{Intent: "prefix", Score: 1, Response: "prefix"},
+		},
+		{
+			name:    "prefix only matches the start",
+			message: "please help",
+			want:    entities.ResponseMatch{Response: "fallback"},
+		},
+		{
+			name:    "regex",
+			message: "order 42",
+			want:    entities.ResponseMatch{Intent: "regex", Score: 1, Response: "regex"},
+		},
+		{
+			name:    "higher priority first",
+			message: "refund",
+			want:    entities.ResponseMatch{Intent: "high_priority", Score: 1, Response: "high priority"},
+		},
+		{
+			name:    "same priority keeps rule order",
+			message: "ticket",
+			want:    entities.ResponseMatch{Intent: "first_of_same_priority", Score: 1, Response: "first"},
+		},
+		{
+			name:    "fallback",
+			message: "something else",
+			want:    entities.ResponseMatch{Response: "fallback"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := engine.Respond(entities.MessageData{Message: tt.message})
+			if got != tt.want {
+				t.Fatalf("Respond(%q) = %+v, want %+v", tt.message, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderResponse(t *testing.T) {
+	data := entities.ResponseTemplateData{
+		TriggerBy:   "user-1",
+		Message:     "weather in Jakarta",
+		Now:         entities.TemplateTime{Time: time.Date(2026, 1, 2, 15, 4, 0, 0, time.UTC)},
+		Groups:      []string{"weather in Jakarta", "Jakarta"},
+		NamedGroups: map[string]string{"city": "Jakarta"},
+		Metadata:    map[string]string{"channel": "web"},
+		Slots:       map[string]string{"name": "Budi"},
+	}
+
+	tests := []struct {
+		name     string
+		response string
+		want     string
+		wantErr  string
+	}{
+		{
+			name:     "plain text",
+			response: "Hi there!",
+			want:     "Hi there!",
+		},
+		{
+			name:     "trigger by and message",
+			response: "{{.TriggerBy}} said {{.Message}}",
+			want:     "user-1 said weather in Jakarta",
+		},
+		{
+			name:     "time",
+			response: "It is {{.Now}}",
+			want:     "It is 2026-01-02 15:04 UTC",
+		},
+		{
+			name:     "time layout",
+			response: `{{.Now.Format "02/01/2006"}}`,
+			want:     "02/01/2026",
+		},
+		{
+			name:     "captured groups",
+			response: "{{index .Groups 1}} or {{.NamedGroups.city}}",
+			want:     "Jakarta or Jakarta",
+		},
+		{
+			name:     "metadata and slots",
+			response: "{{.Slots.name}} from {{.Metadata.channel}}",
+			want:     "Budi from web",
+		},
+		{
+			name:     "missing map key is empty",
+			response: "[{{.Metadata.unknown}}]",
+			want:     "[]",
+		},
+		{
+			name:     "invalid template",
+			response: "{{.Message",
+			wantErr:  "invalid response template",
+		},
+		{
+			name:     "unknown field",
+			response: "{{.Unknown}}",
+			wantErr:  "can't evaluate field Unknown",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := parseResponseTemplate(tt.name, tt.response)
+			if err == nil {
+				var got string
+				got, err = renderResponse(tmpl, data)
+				if err == nil && got != tt.want {
+					t.Fatalf("renderResponse() = %q, want %q", got, tt.want)
+				}
+			}
+
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("renderResponse() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("renderResponse() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRuleEngineRespondTemplate(t *testing.T) {
+	engine, err := NewResponseEngine(entities.ResponseRuleSet{
+		FallbackResponse: "Sorry {{.TriggerBy}}, I don't know {{.Message}}",
+		Rules: []entities.ResponseRule{
+			{
+				Intent:   "weather_city",
+				Type:     entities.MatchTypeRegex,
+				Pattern:  `(?i)^weather in (?P<city>.+)$`,
+				Response: "Sunny in {{.NamedGroups.city}} for {{.TriggerBy}}",
+			},
+			{
+				Intent:   "order",
+				Type:     entities.MatchTypeRegex,
+				Pattern:  `^order (\d+)$`,
+				Response: "Order {{index .Groups 1}} is on its way",
+			},
+			{
+				Intent:   "channel",
+				Type:     entities.MatchTypeExact,
+				Pattern:  "channel",
+				Response: "You are on {{.Metadata.channel}}",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewResponseEngine() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		args entities.MessageData
+		want string
+	}{
+		{
+			name: "named group",
+			args: entities.MessageData{TriggerBy: "user-1", Message: "Weather in Bandung"},
+			want: "Sunny in Bandung for user-1",
+		},
+		{
+			name: "indexed group",
+			args: entities.MessageData{Message: "order 42"},
+			want: "Order 42 is on its way",
+		},
+		{
+			name: "metadata",
+			args: entities.MessageData{Message: "channel", Metadata: map[string]string{"channel": "web"}},
+			want: "You are on web",
+		},
+		{
+			name: "fallback template",
+			args: entities.MessageData{TriggerBy: "user-1", Message: "cats"},
+			want: "Sorry user-1, I don't know cats",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := engine.Respond(tt.args)
+			if got.Response != tt.want {
+				t.Fatalf("Respond(%q) = %q, want %q", tt.args.Message, got.Response, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package service
+
+//go:generate mockery --dir=$PROJECT_DIR/internal/app/service  --name=StreamSvc --filename=$GOFILE --output=$PROJECT_DIR/internal/generated/mock_service --outpkg=mock_service
+
+import (
+	"context"
+	"encoding/json"
+
+	"message-service-kata/internal/app/infra"
+	"message-service-kata/internal/app/repo/postgres"
+	"message-service-kata/pkg/cerror"
+	"message-service-kata/pkg/domain/entities"
+
+	"github.com/rs/zerolog/log"
+	"go.uber.org/dig"
+)
+
+const (
+	// streamBufferSize is the number of live replies a stream may fall behind before it is closed
+	streamBufferSize = 1024
+	// streamBackfillLimit is the page size of stored messages sent when a stream is resumed
+	streamBackfillLimit = 100
+)
+
+type (
+	// StreamSvc interfacing processed message stream service function
+	StreamSvc interface {
+		Subscribe(ctx context.Context, args *entities.StreamMessageRequest) (messages <-chan entities.ConsumedMessage, err error)
+	}
+
+	// StreamSvcImpl implementing processed message stream service dependencies
+	StreamSvcImpl struct {
+		dig.In
+		MessageRepo   postgres.MessageRepository
+		KafkaCfg      *infra.KafkaCfg
+		ReplyRegistry ReplyRegistry
+	}
+)
+
+// NewStreamSvc initiating processed message stream service
+func NewStreamSvc(impl StreamSvcImpl) StreamSvc {
+	return &impl
+}
+
+// Subscribe service to stream processed messages as they are stored by the consumer. When LastEventID is set
+// the stored messages after it are sent first. The channel is closed when ctx is done or the stream falls
+// too far behind, the caller is expected to resume using the ID of the last received message.
+func (s *StreamSvcImpl) Subscribe(
+	ctx context.Context, args *entities.StreamMessageRequest,
+) (messages <-chan entities.ConsumedMessage, err error) {
+	if s.KafkaCfg.ReplyTopic == "" {
+		return nil, cerror.ErrReplyDisabled
+	}
+
+	// Subscribe before reading stored messages so nothing stored in between is missed
+	replies, unsubscribe := s.ReplyRegistry.Subscribe(streamBufferSize)
+
+	ch := make(chan entities.ConsumedMessage)
+	go func() {
+		defer close(ch)
+		defer unsubscribe()
+
+		lastID := args.LastEventID
+		if lastID > 0 {
+			var err error
+			lastID, err = s.backfill(ctx, args.TriggerBy, lastID, ch)
+			if err != nil {
+				log.Error().Msgf("[StreamSvc][Subscribe] error while backfill after %d : %v", args.LastEventID, err)
+				return
+			}
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case reply, ok := <-replies:
+				if !ok {
+					log.Warn().Msgf("[StreamSvc][Subscribe] stream of %q fell behind, closing it", args.TriggerBy)
+					return
+				}
+
+				// Skip replies which are not stored or were already sent by backfill
+				if reply.ConsumedMessageID <= lastID {
+					continue
+				}
+
+				if args.TriggerBy != "" && reply.TriggerBy != args.TriggerBy {
+					continue
+				}
+
+				message, err := replyToConsumedMessage(reply)
+				if err != nil {
+					log.Error().Msgf("[StreamSvc][Subscribe] error while convert reply %d : %v", reply.ConsumedMessageID, err)
+					continue
+				}
+
+				if !sendConsumedMessage(ctx, ch, message) {
+					return
+				}
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// backfill send stored messages after the given id and return id of the last sent message
+func (s *StreamSvcImpl) backfill(
+	ctx context.Context, triggerBy string, afterID int64, ch chan<- entities.ConsumedMessage,
+) (lastID int64, err error) {
+	lastID = afterID
+
+	for {
+		messages, err := s.MessageRepo.ListAfter(ctx, lastID, triggerBy, streamBackfillLimit)
+		if err != nil {
+			return lastID, err
+		}
+
+		for _, message := range messages {
+			if !sendConsumedMessage(ctx, ch, message) {
+				return lastID, ctx.Err()
+			}
+			lastID = message.ID
+		}
+
+		if len(messages) < streamBackfillLimit {
+			return lastID, nil
+		}
+	}
+}
+
+// sendConsumedMessage send message to the stream unless ctx is done
+func sendConsumedMessage(ctx context.Context, ch chan<- entities.ConsumedMessage, message entities.ConsumedMessage) bool {
+	select {
+	case ch <- message:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// replyToConsumedMessage build consumed message from reply in the same shape as it is stored
+func replyToConsumedMessage(reply entities.MessageReplyEvent) (message entities.ConsumedMessage, err error) {
+	data := map[string]interface{}{
+		"received_message": reply.Message,
+		"response_message": reply.Response,
+	}
+	if reply.Intent != "" {
+		data["intent"] = reply.Intent
+		data["intent_score"] = reply.IntentScore
+	}
+	if len(reply.Metadata) > 0 {
+		data["metadata"] = reply.Metadata
+	}
+
+	byt, err := json.Marshal(data)
+	if err != nil {
+		return message, err
+	}
+
+	// Replies published before source type was introduced are chat replies
+	sourceType := reply.SourceType
+	if sourceType == "" {
+		sourceType = entities.SourceTypeChat
+	}
+
+	return entities.ConsumedMessage{
+		ID:             reply.ConsumedMessageID,
+		Message:        byt,
+		TriggerBy:      reply.TriggerBy,
+		ConversationID: reply.ConversationID,
+		ReceivedAt:     reply.RepliedAt,
+		SourceType:     sourceType,
+	}, nil
+}
@@ -42,3 +42,25 @@ var ErrPublishMessage = errors.New("failed publish message")
 
 // ErrNoRowsMessage error when get data from database
 var ErrNoRowsMessage = errors.New("sql: no rows in result set")
+
+// ErrInvalidResponseRule error when chatbot response rule can not be compiled
+var ErrInvalidResponseRule = errors.New("invalid chatbot response rule")
+
+// ErrReplyTimeout error when chatbot reply is not received in time
+var ErrReplyTimeout = errors.New("timeout waiting chatbot reply")
+
+// ErrReplyDisabled error when reply topic is not configured
+var ErrReplyDisabled = errors.New("chatbot reply topic is not configured")
+
+// ErrDeadLetterNotFound error when dead-letter message is not in the dead-letter queue
+var ErrDeadLetterNotFound = errors.New("dead-letter message not found")
+
+// ErrDuplicateMessage error when message of the same source is already stored
+var ErrDuplicateMessage = errors.New("message of the source is already stored")
+
+// ErrOutboxHeldBack error when outbox message is not published behind a failed message of its key or after the relay
+// round stopped, it is left pending for the next round
+var ErrOutboxHeldBack = errors.New("outbox message held back behind failed message of its key")
+
+// ErrConversationOwner error when conversation belongs to another user
+var ErrConversationOwner = errors.New("conversation belongs to another user")
@@ -0,0 +1,81 @@
+package cerror
+
+import "errors"
+
+// ErrorClass for data type string, it decides how a consumer handles the failed message
+type ErrorClass string
+
+const (
+	// ClassRetryable error which may succeed when the message is processed again, unclassified errors are retryable
+	ClassRetryable ErrorClass = "retryable"
+	// ClassPermanent error which fails every time the message is processed, e.g. a payload which can not be decoded
+	ClassPermanent ErrorClass = "permanent"
+	// ClassTransientInfra error of an unavailable dependency such as database or broker, unrelated to the message
+	ClassTransientInfra ErrorClass = "transient_infra"
+)
+
+// ClassifiedError wrap error with its class, the wrapped error is still matched by errors.Is and errors.As
+type ClassifiedError struct {
+	Class ErrorClass
+	Err   error
+}
+
+// Error makes it compatible with `error` interface.
+func (e *ClassifiedError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap satisfies the Go 1.13 error wrapper interface.
+func (e *ClassifiedError) Unwrap() error {
+	return e.Err
+}
+
+// WithClass wrap err with class, nil stays nil and an error already classified keeps its class
+func WithClass(err error, class ErrorClass) error {
+	if err == nil {
+		return nil
+	}
+
+	var classified *ClassifiedError
+	if errors.As(err, &classified) {
+		return err
+	}
+
+	return &ClassifiedError{Class: class, Err: err}
+}
+
+// Retryable wrap err as retryable error
+func Retryable(err error) error {
+	return WithClass(err, ClassRetryable)
+}
+
+// Permanent wrap err as permanent error
+func Permanent(err error) error {
+	return WithClass(err, ClassPermanent)
+}
+
+// TransientInfra wrap err as transient infrastructure error
+func TransientInfra(err error) error {
+	return WithClass(err, ClassTransientInfra)
+}
+
+// ClassOf return class of err, unclassified error is retryable. The first class given to an error is kept since
+// WithClass does not classify an error again, so a repository class is not overridden by its callers.
+func ClassOf(err error) ErrorClass {
+	var classified *ClassifiedError
+	if errors.As(err, &classified) {
+		return classified.Class
+	}
+
+	return ClassRetryable
+}
+
+// IsPermanent report whether err is a permanent error
+func IsPermanent(err error) bool {
+	return ClassOf(err) == ClassPermanent
+}
+
+// IsTransientInfra report whether err is a transient infrastructure error
+func IsTransientInfra(err error) bool {
+	return ClassOf(err) == ClassTransientInfra
+}
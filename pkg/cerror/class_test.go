@@ -0,0 +1,98 @@
+package cerror
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestClassOf(t *testing.T) {
+	base := errors.New("boom")
+
+	tests := []struct {
+		name               string
+		err                error
+		want               ErrorClass
+		wantPermanent      bool
+		wantTransientInfra bool
+	}{
+		{name: "nil", err: nil, want: ClassRetryable},
+		{name: "unclassified", err: base, want: ClassRetryable},
+		{name: "retryable", err: Retryable(base), want: ClassRetryable},
+		{name: "permanent", err: Permanent(base), want: ClassPermanent, wantPermanent: true},
+		{name: "transient infra", err: TransientInfra(base), want: ClassTransientInfra, wantTransientInfra: true},
+		{
+			name:          "wrapped classified error",
+			err:           fmt.Errorf("while process: %w", Permanent(base)),
+			want:          ClassPermanent,
+			wantPermanent: true,
+		},
+		{
+			name:               "first class is kept",
+			err:                Retryable(TransientInfra(base)),
+			want:               ClassTransientInfra,
+			wantTransientInfra: true,
+		},
+		{
+			name:          "first class is kept through wrapping",
+			err:           TransientInfra(fmt.Errorf("while process: %w", Permanent(base))),
+			want:          ClassPermanent,
+			wantPermanent: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassOf(tt.err); got != tt.want {
+				t.Fatalf("ClassOf() = %s, want %s", got, tt.want)
+			}
+
+			if got := IsPermanent(tt.err); got != tt.wantPermanent {
+				t.Fatalf("IsPermanent() = %v, want %v", got, tt.wantPermanent)
+			}
+
+			if got := IsTransientInfra(tt.err); got != tt.wantTransientInfra {
+				t.Fatalf("IsTransientInfra() = %v, want %v", got, tt.wantTransientInfra)
+			}
+		})
+	}
+}
+
+func TestWithClass(t *testing.T) {
+	base := errors.New("boom")
+
+	tests := []struct {
+		name    string
+		err     error
+		class   ErrorClass
+		wantNil bool
+	}{
+		{name: "nil stays nil", err: nil, class: ClassPermanent, wantNil: true},
+		{name: "unclassified", err: base, class: ClassPermanent},
+		{name: "wrapped", err: fmt.Errorf("while process: %w", base), class: ClassTransientInfra},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WithClass(tt.err, tt.class)
+			if tt.wantNil {
+				if got != nil {
+					t.Fatalf("WithClass() = %v, want nil", got)
+				}
+				return
+			}
+
+			if !errors.Is(got, base) {
+				t.Fatalf("WithClass() = %v, does not wrap %v", got, base)
+			}
+
+			if got.Error() != tt.err.Error() {
+				t.Fatalf("WithClass() message = %q, want %q", got.Error(), tt.err.Error())
+			}
+
+			if ClassOf(got) != tt.class {
+				t.Fatalf("ClassOf(WithClass()) = %s, want %s", ClassOf(got), tt.class)
+			}
+		})
+	}
+}
@@ -1,6 +1,58 @@
 package ckafka
 
+import "strings"
+
 const (
 	// TopicProductPorfolioCheckout is define portfolio.checkout_success topic
 	TopicProductPorfolioCheckout = "portfolio.checkout_success"
+
+	// DeadLetterSuffix is the suffix of the dead-letter queue topic of a topic
+	DeadLetterSuffix = "-dead-letter-queue"
+)
+
+const (
+	// HeaderRetryAttempt is the number of retry topics the message went through
+	HeaderRetryAttempt = "x-retry-attempt"
+	// HeaderOriginalTopic is the topic the message was first consumed from
+	HeaderOriginalTopic = "x-original-topic"
+	// HeaderOriginalPartition is the partition the message was first consumed from
+	HeaderOriginalPartition = "x-original-partition"
+	// HeaderOriginalOffset is the offset the message was first consumed from
+	HeaderOriginalOffset = "x-original-offset"
+	// HeaderError is the last error of the message
+	HeaderError = "x-error"
+	// HeaderErrorClass is the class of the last error of the message, retryable, permanent or transient_infra
+	HeaderErrorClass = "x-error-class"
+	// HeaderErrorType is the type of the innermost last error of the message
+	HeaderErrorType = "x-error-type"
+	// HeaderAttempts is the number of times the message was processed over every topic
+	HeaderAttempts = "x-attempts"
+	// HeaderFailedAt is the time of the last failure in RFC 3339
+	HeaderFailedAt = "x-failed-at"
+	// HeaderConsumerHost is the host of the consumer which forwarded the message
+	HeaderConsumerHost = "x-consumer-host"
 )
+
+// FailureHeaders are the headers set by the consumer when it forwards a failed message
+var FailureHeaders = map[string]struct{}{
+	HeaderRetryAttempt:      {},
+	HeaderOriginalTopic:     {},
+	HeaderOriginalPartition: {},
+	HeaderOriginalOffset:    {},
+	HeaderError:             {},
+	HeaderErrorClass:        {},
+	HeaderErrorType:         {},
+	HeaderAttempts:          {},
+	HeaderFailedAt:          {},
+	HeaderConsumerHost:      {},
+}
+
+// DeadLetterTopic return name of the dead-letter queue of topic
+func DeadLetterTopic(topic string) string {
+	return topic + DeadLetterSuffix
+}
+
+// DeadLetterOriginTopic return the topic of the dead-letter queue topic
+func DeadLetterOriginTopic(dlqTopic string) string {
+	return strings.TrimSuffix(dlqTopic, DeadLetterSuffix)
+}
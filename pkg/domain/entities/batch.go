@@ -0,0 +1,63 @@
+package entities
+
+import "time"
+
+// BatchStatus for data type string
+type BatchStatus string
+
+const (
+	// BatchStatusPublishing batch still has messages waiting to be published
+	BatchStatusPublishing BatchStatus = "publishing"
+	// BatchStatusProcessing all messages are published and waiting to be persisted by consumer
+	BatchStatusProcessing BatchStatus = "processing"
+	// BatchStatusCompleted all published messages are persisted by consumer
+	BatchStatusCompleted BatchStatus = "completed"
+)
+
+// MessageBatch the structure for tracking messages produced by one post message request.
+type MessageBatch struct {
+	ID        int64       `json:"id"`
+	TriggerBy string      `json:"trigger_by"`
+	Status    BatchStatus `json:"status"`
+	Requested int64       `json:"requested"`
+	Published int64       `json:"published"`
+	Failed    int64       `json:"failed"`
+	Consumed  int64       `json:"consumed"`
+	Persisted int64       `json:"persisted"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+
+	Failures []PublishFailure `json:"failures,omitempty"`
+}
+
+// PublishFailure the structure for message of a batch which failed to be published.
+type PublishFailure struct {
+	Index   int    `json:"index"`
+	Message string `json:"message"`
+	Error   string `json:"error"`
+}
+
+// BatchCounters the structure for incrementing message batch counters.
+type BatchCounters struct {
+	Published int64
+	Failed    int64
+	Consumed  int64
+	Persisted int64
+}
+
+// GetBatchRequest the structure for get message batch request.
+type GetBatchRequest struct {
+	ID int64 `param:"id" validate:"required,gt=0"`
+}
+
+// ResolveStatus derive batch status from its counters
+func (b *MessageBatch) ResolveStatus() BatchStatus {
+	switch {
+	case b.Published+b.Failed < b.Requested:
+		return BatchStatusPublishing
+	case b.Persisted < b.Published:
+		return BatchStatusProcessing
+	default:
+		return BatchStatusCompleted
+	}
+}
@@ -0,0 +1,66 @@
+package entities
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	// CheckoutTemplateCongratulation template of the message sent for the first checkout of a user
+	CheckoutTemplateCongratulation = "congratulation"
+	// CheckoutTemplateConfirmation template of the message sent for the other checkouts
+	CheckoutTemplateConfirmation = "confirmation"
+)
+
+// CheckoutEvent the structure for portfolio.checkout_success event published when a user checks a product out.
+// Amount is kept as sent so it is printed without float formatting.
+type CheckoutEvent struct {
+	EventID       string      `json:"event_id"`
+	OrderID       string      `json:"order_id"`
+	UserID        string      `json:"user_id"`
+	UserName      string      `json:"user_name"`
+	ProductName   string      `json:"product_name"`
+	ProductType   string      `json:"product_type"`
+	Amount        json.Number `json:"amount"`
+	Currency      string      `json:"currency"`
+	FirstPurchase bool        `json:"first_purchase"`
+	CheckedOutAt  time.Time   `json:"checked_out_at"`
+}
+
+// CheckoutTemplateData the structure for data available to checkout message template, for example
+// "Hi {{.UserName}}, your {{.ProductName}} order {{.OrderID}} is confirmed".
+type CheckoutTemplateData struct {
+	CheckoutEvent
+	Now TemplateTime
+}
+
+// DefaultCheckoutTemplates predefined checkout message templates by template name
+var DefaultCheckoutTemplates = map[string]string{
+	CheckoutTemplateCongratulation: "Congratulations{{with .UserName}} {{.}}{{end}}! 🎉 Your first investment in " +
+		"{{.ProductName}} of {{.Currency}} {{.Amount}} is confirmed. Welcome aboard!",
+	CheckoutTemplateConfirmation: "Hi{{with .UserName}} {{.}}{{end}}, your checkout of {{.ProductName}} of " +
+		"{{.Currency}} {{.Amount}} is confirmed. ✅ Order {{.OrderID}}",
+}
+
+// Validate check the fields required to address and identify the checkout message
+func (e *CheckoutEvent) Validate() error {
+	if e.UserID == "" {
+		return errors.New("user_id is required")
+	}
+
+	if e.OrderID == "" {
+		return errors.New("order_id is required")
+	}
+
+	return nil
+}
+
+// Template return name of the message template of the checkout
+func (e *CheckoutEvent) Template() string {
+	if e.FirstPurchase {
+		return CheckoutTemplateCongratulation
+	}
+
+	return CheckoutTemplateConfirmation
+}
@@ -0,0 +1,20 @@
+package entities
+
+import "time"
+
+// Conversation the structure for conversation of a user and its transcript ordered by oldest first.
+type Conversation struct {
+	ID        string    `json:"id"`
+	TriggerBy string    `json:"trigger_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Messages []ConsumedMessage `json:"messages"`
+}
+
+// GetConversationRequest the structure for get conversation transcript request, TriggerBy is the user
+// the conversation belongs to.
+type GetConversationRequest struct {
+	ID        string `param:"id" validate:"required,max=64"`
+	TriggerBy string `query:"trigger_by" validate:"required"`
+}
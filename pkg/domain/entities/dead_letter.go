@@ -0,0 +1,94 @@
+package entities
+
+import "time"
+
+// DeadLetterMessage the structure for message of a dead-letter queue with its failure metadata.
+// Headers hold the headers of the original message, failure headers are decoded into their own fields.
+type DeadLetterMessage struct {
+	Topic             string            `json:"topic"`
+	Partition         int32             `json:"partition"`
+	Offset            int64             `json:"offset"`
+	Key               string            `json:"key"`
+	Value             string            `json:"value"`
+	Timestamp         time.Time         `json:"timestamp"`
+	OriginalTopic     string            `json:"original_topic"`
+	OriginalPartition int32             `json:"original_partition"`
+	OriginalOffset    int64             `json:"original_offset"`
+	Error             string            `json:"error"`
+	ErrorClass        string            `json:"error_class"`
+	ErrorType         string            `json:"error_type"`
+	Attempts          int               `json:"attempts"`
+	FailedAt          time.Time         `json:"failed_at"`
+	ConsumerHost      string            `json:"consumer_host"`
+	Headers           map[string]string `json:"headers,omitempty"`
+}
+
+// DeadLetterFilter the structure for filtering dead-letter messages, empty fields match every message.
+type DeadLetterFilter struct {
+	ErrorClass string    `query:"error_class"`
+	Key        string    `query:"key"`
+	FailedFrom time.Time `query:"failed_from"`
+	FailedTo   time.Time `query:"failed_to"`
+}
+
+// ListDeadLetterRequest the structure for list dead-letter messages request, Topic is the original topic.
+type ListDeadLetterRequest struct {
+	Topic string `query:"topic" validate:"required"`
+	DeadLetterFilter
+	Limit int `query:"limit" validate:"gte=0,lte=500"`
+}
+
+// GetDeadLetterRequest the structure for get dead-letter message request, Topic is the original topic.
+type GetDeadLetterRequest struct {
+	Topic     string `query:"topic" validate:"required"`
+	Partition int32  `param:"partition" validate:"gte=0"`
+	Offset    int64  `param:"offset" validate:"gte=0"`
+}
+
+// ReplayDeadLetterRequest the structure for replaying dead-letter messages of the original topic. Rate is the
+// maximum republished messages per second, zero is unlimited. Dry run neither republishes nor commits.
+// GroupID keeps the progress of the replay apart from the default replay group when set.
+type ReplayDeadLetterRequest struct {
+	Topic   string
+	GroupID string
+	DryRun  bool
+	Rate    float64
+	DeadLetterFilter
+}
+
+// DeadLetterReplayResult the structure for summary of dead-letter replay, StoppedPartitions are partitions left
+// at a message filtered out.
+type DeadLetterReplayResult struct {
+	Scanned           int64 `json:"scanned"`
+	Matched           int64 `json:"matched"`
+	Replayed          int64 `json:"replayed"`
+	StoppedPartitions int64 `json:"stopped_partitions"`
+	DryRun            bool  `json:"dry_run"`
+}
+
+// Match report whether the dead-letter message passes the filter, failure time falls back to the message
+// timestamp for messages without failure headers
+func (f *DeadLetterFilter) Match(msg *DeadLetterMessage) bool {
+	if f.ErrorClass != "" && msg.ErrorClass != f.ErrorClass {
+		return false
+	}
+
+	if f.Key != "" && msg.Key != f.Key {
+		return false
+	}
+
+	failedAt := msg.FailedAt
+	if failedAt.IsZero() {
+		failedAt = msg.Timestamp
+	}
+
+	if !f.FailedFrom.IsZero() && failedAt.Before(f.FailedFrom) {
+		return false
+	}
+
+	if !f.FailedTo.IsZero() && !failedAt.Before(f.FailedTo) {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,72 @@
+package entities
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeadLetterFilterMatch(t *testing.T) {
+	failedAt := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	msg := &DeadLetterMessage{
+		Key:        "user-1",
+		ErrorClass: "retryable",
+		FailedAt:   failedAt,
+		Timestamp:  failedAt.Add(time.Hour),
+	}
+	legacy := &DeadLetterMessage{Key: "user-1", Timestamp: failedAt}
+
+	tests := []struct {
+		name   string
+		filter DeadLetterFilter
+		msg    *DeadLetterMessage
+		want   bool
+	}{
+		{name: "empty filter", msg: msg, want: true},
+		{name: "error class", filter: DeadLetterFilter{ErrorClass: "retryable"}, msg: msg, want: true},
+		{name: "other error class", filter: DeadLetterFilter{ErrorClass: "permanent"}, msg: msg, want: false},
+		{name: "key", filter: DeadLetterFilter{Key: "user-1"}, msg: msg, want: true},
+		{name: "other key", filter: DeadLetterFilter{Key: "user-2"}, msg: msg, want: false},
+		{name: "failed from is inclusive", filter: DeadLetterFilter{FailedFrom: failedAt}, msg: msg, want: true},
+		{name: "failed before from", filter: DeadLetterFilter{FailedFrom: failedAt.Add(time.Second)}, msg: msg, want: false},
+		{name: "failed before to", filter: DeadLetterFilter{FailedTo: failedAt.Add(time.Second)}, msg: msg, want: true},
+		{name: "failed to is exclusive", filter: DeadLetterFilter{FailedTo: failedAt}, msg: msg, want: false},
+		{
+			name:   "within range",
+			filter: DeadLetterFilter{FailedFrom: failedAt.Add(-time.Hour), FailedTo: failedAt.Add(time.Hour)},
+			msg:    msg,
+			want:   true,
+		},
+		{
+			name:   "failure time is used over the timestamp",
+			filter: DeadLetterFilter{FailedFrom: failedAt.Add(30 * time.Minute)},
+			msg:    msg,
+			want:   false,
+		},
+		{
+			name:   "timestamp without failure headers",
+			filter: DeadLetterFilter{FailedFrom: failedAt, FailedTo: failedAt.Add(time.Second)},
+			msg:    legacy,
+			want:   true,
+		},
+		{
+			name:   "error class without failure headers",
+			filter: DeadLetterFilter{ErrorClass: "retryable"},
+			msg:    legacy,
+			want:   false,
+		},
+		{
+			name:   "every field has to match",
+			filter: DeadLetterFilter{ErrorClass: "retryable", Key: "user-2", FailedFrom: failedAt},
+			msg:    msg,
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(tt.msg); got != tt.want {
+				t.Fatalf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
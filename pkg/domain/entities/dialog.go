@@ -0,0 +1,83 @@
+package entities
+
+import "time"
+
+// DialogFlowSet the structure for set of dialog flows, flows are only used for messages of a conversation.
+type DialogFlowSet struct {
+	Flows []DialogFlow `json:"flows" yaml:"flows"`
+}
+
+// DialogFlow the structure for multi-turn dialog. A trigger starts the flow from idle conversation,
+// then the transitions of the current state are matched against the next message.
+type DialogFlow struct {
+	Name     string             `json:"name" yaml:"name"`
+	Triggers []DialogTransition `json:"triggers" yaml:"triggers"`
+	States   []DialogState      `json:"states" yaml:"states"`
+}
+
+// DialogState the structure for state of dialog flow. Fallback is replied when no transition matches
+// and the state is kept, without fallback the dialog is abandoned and the message is answered by response rules.
+type DialogState struct {
+	Name        string             `json:"name" yaml:"name"`
+	Transitions []DialogTransition `json:"transitions" yaml:"transitions"`
+	Fallback    string             `json:"fallback" yaml:"fallback"`
+}
+
+// DialogTransition the structure for transition matching message like response rule. The message, or the regex
+// named group with the same name, is saved to Slot. Empty Next ends the dialog.
+type DialogTransition struct {
+	Intent   string    `json:"intent" yaml:"intent"`
+	Type     MatchType `json:"type" yaml:"type"`
+	Pattern  string    `json:"pattern" yaml:"pattern"`
+	Response string    `json:"response" yaml:"response"`
+	Slot     string    `json:"slot" yaml:"slot"`
+	Next     string    `json:"next" yaml:"next"`
+}
+
+// DialogSession the structure for dialog state of a conversation, empty Flow means the conversation is idle.
+type DialogSession struct {
+	ConversationID string            `json:"conversation_id"`
+	Flow           string            `json:"flow"`
+	State          string            `json:"state"`
+	Slots          map[string]string `json:"slots"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+}
+
+// DefaultDialogFlows predefined dialog flows
+var DefaultDialogFlows = DialogFlowSet{
+	Flows: []DialogFlow{
+		{
+			Name: "weather",
+			Triggers: []DialogTransition{
+				{
+					Intent:   "weather",
+					Type:     MatchTypeCaseInsensitive,
+					Pattern:  "Weather update",
+					Response: "Which city? 🏙",
+					Next:     "ask_city",
+				},
+			},
+			States: []DialogState{
+				{
+					Name: "ask_city",
+					Transitions: []DialogTransition{
+						{
+							Intent:   "cancel",
+							Type:     MatchTypeRegex,
+							Pattern:  `(?i)^(cancel|never ?mind)\W*$`,
+							Response: "Okay, never mind. 👍",
+						},
+						{
+							Intent:   "weather_city",
+							Type:     MatchTypeRegex,
+							Pattern:  `^(?:(?i)in |for )?(?P<city>[^?!.]+)[?!.]*$`,
+							Response: "The weather in {{.Slots.city}} is sunny and bright! ☀",
+							Slot:     "city",
+						},
+					},
+					Fallback: "Sorry, which city do you mean? 🏙",
+				},
+			},
+		},
+	},
+}
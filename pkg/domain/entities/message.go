@@ -1,34 +1,163 @@
 package entities
 
+import (
+	"encoding/json"
+	"time"
+)
+
+// DefaultListLimit default page size used when listing consumed messages.
+const DefaultListLimit = 20
+
 // CreateMessageRequest the structure for create message request.
+// When Messages is empty the predefined Queries are published.
+// Messages of a conversation are published in order, keyed by ConversationID.
 type CreateMessageRequest struct {
-	TriggerBy string `json:"trigger_by" validate:"required"`
-	Qty       int64  `json:"qty" validate:"required,gte=0"`
+	TriggerBy      string              `json:"trigger_by" validate:"required"`
+	ConversationID string              `json:"conversation_id" validate:"omitempty,max=64"`
+	Qty            int64               `json:"qty" validate:"required,gt=0,lte=10000"`
+	Messages       []CreateMessageItem `json:"messages" validate:"omitempty,max=100,dive"`
+}
+
+// Requested number of messages the request publishes, every qty publishes each message once
+func (r *CreateMessageRequest) Requested() int64 {
+	count := len(r.Messages)
+	if count == 0 {
+		count = len(Queries)
+	}
+
+	return r.Qty * int64(count)
+}
+
+// CreateMessageItem the structure for user supplied message of create message request.
+type CreateMessageItem struct {
+	Message  string            `json:"message" validate:"required,max=4096"`
+	Key      string            `json:"key" validate:"omitempty,max=255"`
+	Metadata map[string]string `json:"metadata" validate:"omitempty,max=20,dive,keys,required,max=64,endkeys,max=1024"`
+}
+
+// ChatRequest the structure for chat request, posted to the chat endpoint or sent as websocket frame.
+type ChatRequest struct {
+	TriggerBy      string            `json:"trigger_by" validate:"required"`
+	ConversationID string            `json:"conversation_id" validate:"omitempty,max=64"`
+	Message        string            `json:"message" validate:"required,max=4096"`
+	Key            string            `json:"key" validate:"omitempty,max=255"`
+	Metadata       map[string]string `json:"metadata" validate:"omitempty,max=20,dive,keys,required,max=64,endkeys,max=1024"`
 }
 
 // MessageData the structure for message data.
+// ID identifies a published message and is used as correlation ID of its reply.
+// SessionID routes the reply back to the chat session which sent the message.
 type MessageData struct {
-	Message   string `json:"message"`
-	TriggerBy string `json:"trigger_by"`
+	ID             string            `json:"id,omitempty"`
+	SessionID      string            `json:"session_id,omitempty"`
+	ConversationID string            `json:"conversation_id,omitempty"`
+	Message        string            `json:"message"`
+	TriggerBy      string            `json:"trigger_by"`
+	BatchID        int64             `json:"batch_id,omitempty"`
+	Metadata       map[string]string `json:"metadata,omitempty"`
+
+	// SourceType is the origin of the stored message, chat when empty
+	SourceType SourceType `json:"source_type,omitempty"`
+	// SourceID identifies the event of the source the message is stored for, it is stored once per source ID
+	SourceID string `json:"source_id,omitempty"`
+}
+
+// ConsumedMessage the structure for consumed message stored by the consumer.
+type ConsumedMessage struct {
+	ID             int64           `json:"id"`
+	Message        json.RawMessage `json:"message"`
+	TriggerBy      string          `json:"trigger_by"`
+	ConversationID string          `json:"conversation_id,omitempty"`
+	ReceivedAt     time.Time       `json:"received_at"`
+
+	SourceType SourceType `json:"source_type"`
+}
+
+// ListMessageRequest the structure for list consumed message request.
+type ListMessageRequest struct {
+	TriggerBy       string    `query:"trigger_by"`
+	ReceivedMessage string    `query:"received_message"`
+	ResponseMessage string    `query:"response_message"`
+	ReceivedFrom    time.Time `query:"received_from"`
+	ReceivedTo      time.Time `query:"received_to"`
+	Cursor          int64     `query:"cursor" validate:"gte=0"`
+	Limit           int       `query:"limit" validate:"gte=0,lte=100"`
+
+	SourceType SourceType `query:"source_type"`
 }
 
+// ChatFrameType for data type string
+type ChatFrameType string
+
+const (
+	// ChatFrameAck acknowledge inbound chat message is published, ID is the message ID
+	ChatFrameAck ChatFrameType = "ack"
+	// ChatFrameReply chatbot reply of a published chat message
+	ChatFrameReply ChatFrameType = "reply"
+	// ChatFrameError inbound chat message is rejected or failed to be published
+	ChatFrameError ChatFrameType = "error"
+)
+
+// ChatFrame the structure for outbound websocket chat frame.
+type ChatFrame struct {
+	Type  ChatFrameType      `json:"type"`
+	ID    string             `json:"id,omitempty"`
+	Reply *MessageReplyEvent `json:"reply,omitempty"`
+	Error string             `json:"error,omitempty"`
+}
+
+// StreamMessageRequest the structure for stream consumed message request.
+// LastEventID is read from the Last-Event-ID header to resume after the given consumed message.
+type StreamMessageRequest struct {
+	TriggerBy   string `query:"trigger_by"`
+	LastEventID int64  `json:"-" validate:"gte=0"`
+}
+
+// GetMessageRequest the structure for get consumed message request.
+type GetMessageRequest struct {
+	ID int64 `param:"id" validate:"required,gt=0"`
+}
+
+// SourceType for data type string
+type SourceType string
+
+const (
+	// SourceTypeChat message sent by a user and answered by the chatbot
+	SourceTypeChat SourceType = "chat"
+	// SourceTypeCheckout message sent to a user for a portfolio checkout event
+	SourceTypeCheckout SourceType = "checkout"
+)
+
 // KafkaTopic for data type string
 type KafkaTopic string
 
 const (
 	// TopicPublishMessage to consume topic from producer message
 	TopicPublishMessage KafkaTopic = "message.publish"
+	// TopicResponseInvalidate to notify consumer that chatbot responses are changed
+	TopicResponseInvalidate KafkaTopic = "chatbot.response.invalidate"
+	// TopicMessageReply default topic of chatbot reply produced by the consumer
+	TopicMessageReply KafkaTopic = "message.reply"
 )
 
-// Predefined chatbot responses
-var Responses = map[string]string{
-	"Hello":          "Hi there! 😊",
-	"Weather update": "The weather is sunny and bright! ☀",
-	"Tell me a joke": "Why did the chicken cross the road? To get to the other side! 😂",
-}
+// MessageReplyEvent the structure for chatbot reply event, CorrelationID is the ID of the replied message.
+// ConsumedMessageID is the stored consumed message, it is empty when the reply could not be stored.
+type MessageReplyEvent struct {
+	CorrelationID     string            `json:"correlation_id"`
+	ConsumedMessageID int64             `json:"consumed_message_id,omitempty"`
+	SessionID         string            `json:"session_id,omitempty"`
+	ConversationID    string            `json:"conversation_id,omitempty"`
+	TriggerBy         string            `json:"trigger_by"`
+	BatchID           int64             `json:"batch_id,omitempty"`
+	Message           string            `json:"message"`
+	Response          string            `json:"response"`
+	Intent            string            `json:"intent,omitempty"`
+	IntentScore       float64           `json:"intent_score,omitempty"`
+	Metadata          map[string]string `json:"metadata,omitempty"`
+	RepliedAt         time.Time         `json:"replied_at"`
 
-// Fallback response
-const FallbackResponse = "I'm sorry, I didn't understand that. 🤔"
+	SourceType SourceType `json:"source_type,omitempty"`
+}
 
 // Define Queries
 var Queries = []string{
@@ -0,0 +1,49 @@
+package entities
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// MessageRequest the structure for record of a post message request, stored together with its outbox messages.
+type MessageRequest struct {
+	ID             int64           `json:"id"`
+	BatchID        int64           `json:"batch_id"`
+	TriggerBy      string          `json:"trigger_by"`
+	ConversationID string          `json:"conversation_id"`
+	Payload        json.RawMessage `json:"payload"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// OutboxMessage the structure for message waiting in the outbox to be published by the relay.
+// BatchID is zero for message which does not belong to a message batch.
+type OutboxMessage struct {
+	ID        int64           `json:"id"`
+	RequestID int64           `json:"request_id"`
+	BatchID   int64           `json:"batch_id"`
+	Topic     string          `json:"topic"`
+	Key       string          `json:"key"`
+	Payload   json.RawMessage `json:"payload"`
+	Attempts  int             `json:"attempts"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// OutboxRelayRequest the structure for one relay round. Limit is the number of claimed messages, a failed message
+// is published again after RetryBackoff doubled on every attempt up to RetryBackoffMax and is parked after MaxAttempts.
+type OutboxRelayRequest struct {
+	Limit           int
+	MaxAttempts     int
+	RetryBackoff    time.Duration
+	RetryBackoffMax time.Duration
+}
+
+// OutboxRelayResult the structure for summary of one relay round over the claimed outbox messages.
+// HeldBack are messages left pending behind a failed message of their key, Parked are failed messages
+// which reached the max attempts and are no longer published.
+type OutboxRelayResult struct {
+	Claimed  int `json:"claimed"`
+	Sent     int `json:"sent"`
+	Failed   int `json:"failed"`
+	HeldBack int `json:"held_back"`
+	Parked   int `json:"parked"`
+}
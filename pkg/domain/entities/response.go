@@ -0,0 +1,154 @@
+package entities
+
+import "time"
+
+// MatchType for data type string
+type MatchType string
+
+const (
+	// MatchTypeExact match message which is exactly equal to the pattern
+	MatchTypeExact MatchType = "exact"
+	// MatchTypeCaseInsensitive match message which is equal to the pattern ignoring case
+	MatchTypeCaseInsensitive MatchType = "case_insensitive"
+	// MatchTypeRegex match message using the pattern as regular expression
+	MatchTypeRegex MatchType = "regex"
+	// MatchTypeContains match message which contains the pattern keyword ignoring case
+	MatchTypeContains MatchType = "contains"
+	// MatchTypePrefix match message which starts with the pattern ignoring case
+	MatchTypePrefix MatchType = "prefix"
+)
+
+// ResponseRule the structure for chatbot response rule, rule with higher priority is evaluated first.
+type ResponseRule struct {
+	Intent   string    `json:"intent" yaml:"intent"`
+	Type     MatchType `json:"type" yaml:"type"`
+	Pattern  string    `json:"pattern" yaml:"pattern"`
+	Response string    `json:"response" yaml:"response"`
+	Priority int       `json:"priority" yaml:"priority"`
+}
+
+// ResponseRuleSet the structure for set of chatbot response rules.
+// Version is derived from the rules content when it is empty. MatchThreshold is the minimum
+// similarity score of fuzzy intent matching, zero disables fuzzy matching.
+type ResponseRuleSet struct {
+	Version          string         `json:"version" yaml:"version"`
+	Rules            []ResponseRule `json:"rules" yaml:"rules"`
+	FallbackResponse string         `json:"fallback_response" yaml:"fallback_response"`
+	MatchThreshold   float64        `json:"match_threshold,omitempty" yaml:"match_threshold"`
+}
+
+// ResponseMatch the structure for response chosen by response engine.
+// Intent is empty when no rule matched and fallback response is used. Score is 1 when
+// the rule matched as is, or the similarity score when the intent is fuzzy matched.
+type ResponseMatch struct {
+	Intent   string  `json:"intent,omitempty"`
+	Score    float64 `json:"score,omitempty"`
+	Response string  `json:"response"`
+}
+
+// Fallback response
+const FallbackResponse = "I'm sorry, I didn't understand that. 🤔"
+
+// DefaultResponseRules predefined chatbot responses
+var DefaultResponseRules = ResponseRuleSet{
+	Rules: []ResponseRule{
+		{
+			Intent:   "greeting",
+			Type:     MatchTypeExact,
+			Pattern:  "Hello",
+			Response: "Hi there! 😊",
+			Priority: 100,
+		},
+		{
+			Intent:   "weather",
+			Type:     MatchTypeExact,
+			Pattern:  "Weather update",
+			Response: "The weather is sunny and bright! ☀",
+			Priority: 100,
+		},
+		{
+			Intent:   "weather_city",
+			Type:     MatchTypeRegex,
+			Pattern:  `(?i)^weather (?:in|for) (?P<city>.+?)\??$`,
+			Response: "The weather in {{.NamedGroups.city}} is sunny and bright! ☀",
+			Priority: 100,
+		},
+		{
+			Intent:   "joke",
+			Type:     MatchTypeExact,
+			Pattern:  "Tell me a joke",
+			Response: "Why did the chicken cross the road? To get to the other side! 😂",
+			Priority: 100,
+		},
+		{
+			Intent:   "good_morning",
+			Type:     MatchTypeCaseInsensitive,
+			Pattern:  "Good morning",
+			Response: "Good morning, {{.TriggerBy}}! It's {{.Now.Format \"15:04\"}}, have a great day! 🌅",
+			Priority: 90,
+		},
+		{
+			Intent:   "how_are_you",
+			Type:     MatchTypeRegex,
+			Pattern:  `(?i)^how are you\??$`,
+			Response: "I'm doing great, thanks for asking! 🤖",
+			Priority: 90,
+		},
+	},
+	FallbackResponse: FallbackResponse,
+}
+
+// ChatbotResponse the structure for chatbot response rule stored in database.
+type ChatbotResponse struct {
+	ID int64 `json:"id"`
+	ResponseRule
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// UpsertResponseRequest the structure for create or update chatbot response request.
+type UpsertResponseRequest struct {
+	ID       int64     `param:"id" json:"-"`
+	Intent   string    `json:"intent" validate:"required,max=255"`
+	Type     MatchType `json:"type" validate:"required,oneof=exact case_insensitive regex contains prefix"`
+	Pattern  string    `json:"pattern" validate:"required,max=1024"`
+	Response string    `json:"response" validate:"required,max=4096"`
+	Priority int       `json:"priority"`
+}
+
+// DeleteResponseRequest the structure for delete chatbot response request.
+type DeleteResponseRequest struct {
+	ID int64 `param:"id" validate:"required,gt=0"`
+}
+
+// ResponseInvalidateEvent the structure for chatbot response invalidation event.
+type ResponseInvalidateEvent struct {
+	ResponseID    int64     `json:"response_id"`
+	Action        string    `json:"action"`
+	InvalidatedAt time.Time `json:"invalidated_at"`
+}
+
+// ResponseTemplateData the structure for data available to response template, for example
+// "Good morning, {{.TriggerBy}}! It's {{.Now.Format "15:04"}}" or "Weather in {{index .Groups 1}}".
+type ResponseTemplateData struct {
+	TriggerBy string
+	Message   string
+	Now       TemplateTime
+	// Groups holds the regex captured groups, Groups 0 is the whole match
+	Groups []string
+	// NamedGroups holds the regex named captured groups
+	NamedGroups map[string]string
+	Metadata    map[string]string
+	// Slots holds the values captured by the dialog of the conversation
+	Slots map[string]string
+}
+
+// TemplateTime is time used in response template, printed as "2006-01-02 15:04 MST" by default
+type TemplateTime struct {
+	time.Time
+}
+
+// String format time for response template
+func (t TemplateTime) String() string {
+	return t.Format("2006-01-02 15:04 MST")
+}
@@ -22,6 +22,7 @@ type (
 //nolint:lll
 var (
 	ErrBadRequest          = NewHTTPError(http.StatusBadRequest, DefaultErrorMessage)                         // HTTP 400 Bad Request.
+	ErrForbidden           = NewHTTPError(http.StatusForbidden, ResponseMessageForbidden)                     // HTTP 403 Forbidden.
 	ErrNotFound            = NewHTTPError(http.StatusNotFound, ResponseMessageNotFound)                       // HTTP 404 Not Found.
 	ErrMethodNotAllowed    = NewHTTPError(http.StatusMethodNotAllowed, ResponseMessageMethodNotAllowed)       // HTTP 405 Method Not Allowed.
 	ErrUnprocessableEntity = NewHTTPError(http.StatusUnprocessableEntity, ResponseMessageUnprocessableEntity) // HTTP 422 Unprocessable Entity.
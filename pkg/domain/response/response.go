@@ -20,6 +20,12 @@ type (
 		Meta    interface{} `json:"meta,omitempty"`
 		Errors  []Error     `json:"errors,omitempty"`
 	}
+
+	// CursorMeta holds the cursor pagination meta of list response.
+	CursorMeta struct {
+		Limit      int   `json:"limit"`
+		NextCursor int64 `json:"next_cursor,omitempty"`
+	}
 )
 
 var (
@@ -29,12 +35,24 @@ var (
 		"en": "Success",
 	}
 
+	// ResponseMessageMultiStatus http status: 207 - multi status.
+	ResponseMessageMultiStatus = map[string]string{
+		"id": "Sebagian berhasil",
+		"en": "Partially succeeded",
+	}
+
 	// DefaultErrorMessage http status: 400 - bad request.
 	DefaultErrorMessage = map[string]string{
 		"id": "Gagal",
 		"en": "Failed",
 	}
 
+	// ResponseMessageForbidden http status: 403 - forbidden.
+	ResponseMessageForbidden = map[string]string{
+		"id": "Akses ditolak",
+		"en": "Forbidden",
+	}
+
 	// ResponseMessageNotFound http status: 404 - data not found.
 	ResponseMessageNotFound = map[string]string{
 		"id": "Data tidak ditemukan",
@@ -28,7 +28,7 @@ func Validate(i interface{}) error {
 		if errors.As(err, &errs) {
 			// Add a ErrorMessage to the out array.
 			for i, fe := range errs {
-				out[i] = ErrorMessage(fe.Field(), fe.Tag())
+				out[i] = ErrorMessage(fieldPath(fe), fe.Tag())
 			}
 		}
 
@@ -42,3 +42,14 @@ func Validate(i interface{}) error {
 func ErrorMessage(field, tag string) string {
 	return fmt.Sprintf("Invalid %s value for field %s", tag, field)
 }
+
+// fieldPath return the field path without the root struct name, so nested field
+// like Messages[0].Message can be told apart from the root Message field
+func fieldPath(fe validator.FieldError) string {
+	namespace := fe.Namespace()
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+
+	return fe.Field()
+}